@@ -0,0 +1,142 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/kelseyhightower/compose2kube/converter"
+
+	"golang.org/x/crypto/ssh/terminal"
+	"gopkg.in/yaml.v2"
+)
+
+// answers holds the answers to the catalog questions, nil unless -answers
+// or -prompt is given. The converter then interpolates the compose files
+// with them.
+var answers map[string]string
+
+// readAnswersFile reads a YAML or JSON file mapping variables to answers.
+func readAnswersFile(answersFile string) map[string]string {
+	file, err := ioutil.ReadFile(answersFile)
+	if err != nil {
+		log.Fatalf("Failed to read the answers file %s: %v", answersFile, err)
+	}
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(file, &values); err != nil {
+		log.Fatalf("Failed to parse the answers file %s: %v", answersFile, err)
+	}
+	answers := make(map[string]string, len(values))
+	for variable, value := range values {
+		if value == nil {
+			value = ""
+		}
+		answers[variable] = fmt.Sprint(value)
+	}
+	return answers
+}
+
+// prompt asks a question on the terminal until the answer is valid. Empty
+// answers take the default. Passwords are read without echo, and their
+// default is not shown.
+func prompt(in *bufio.Reader, out io.Writer, question converter.RancherQuestion) string {
+	label := question.Label
+	if label == "" {
+		label = question.Variable
+	}
+	defaultValue := fmt.Sprint(question.DefaultValue())
+	for {
+		fmt.Fprintf(out, "%s (%s)", label, question.Variable)
+		if question.Type == "enum" {
+			fmt.Fprintf(out, " {%s}", strings.Join(question.Options, ", "))
+		}
+		if defaultValue != "" && question.Type != "password" {
+			fmt.Fprintf(out, " [%s]", defaultValue)
+		}
+		fmt.Fprint(out, ": ")
+		line, err := readAnswer(in, out, question.Type == "password")
+		if err != nil && (err != io.EOF || line == "") {
+			log.Fatalf("Failed to read the answer to %s: %v", question.Variable, err)
+		}
+		answer := strings.TrimRight(line, "\r\n")
+		if answer == "" {
+			answer = defaultValue
+		}
+		answer, err = converter.ValidateAnswer(question, answer)
+		if err == nil {
+			return answer
+		}
+		fmt.Fprintf(out, "Invalid answer: %v\n", err)
+	}
+}
+
+// readAnswer reads a line of the terminal, without echo for a password.
+// Answers piped in are read as is.
+func readAnswer(in *bufio.Reader, out io.Writer, password bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !password || !terminal.IsTerminal(fd) {
+		return in.ReadString('\n')
+	}
+	line, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(out)
+	return string(line), err
+}
+
+// loadAnswers answers the catalog questions from the answers file, then the
+// terminal when prompting, then their defaults, and fails on the first
+// invalid answer.
+func loadAnswers(rancherCompose *converter.RancherCompose, answersFile string, interactive bool) map[string]string {
+	given := map[string]string{}
+	if answersFile != "" {
+		given = readAnswersFile(answersFile)
+	}
+	questions := rancherCompose.Questions()
+	hasNamespace := false
+	for _, question := range questions {
+		if question.Variable == "NAMESPACE" {
+			hasNamespace = true
+		}
+	}
+	if !hasNamespace {
+		questions = append([]converter.RancherQuestion{converter.NamespaceQuestion}, questions...)
+	}
+
+	result := make(map[string]string, len(given))
+	for variable, answer := range given {
+		result[variable] = answer
+	}
+	in := bufio.NewReader(os.Stdin)
+	for _, question := range questions {
+		answer, ok := given[question.Variable]
+		if !ok && interactive {
+			result[question.Variable] = prompt(in, os.Stderr, question)
+			continue
+		}
+		if !ok {
+			answer = fmt.Sprint(question.DefaultValue())
+		}
+		answer, err := converter.ValidateAnswer(question, answer)
+		if err != nil {
+			log.Fatalf("Invalid answer to %s: %v", question.Variable, err)
+		}
+		result[question.Variable] = answer
+	}
+	return result
+}
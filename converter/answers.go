@@ -0,0 +1,213 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/docker/libcompose/config"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// ComposeVariableRegexp matches the $NAME, ${NAME}, ${NAME-default} and
+	// ${NAME:-default} compose variables.
+	ComposeVariableRegexp = regexp.MustCompile(`\$(?:\{([a-zA-Z_][a-zA-Z0-9_]*)(?:(:?-)([^}]*))?\}|([a-zA-Z_][a-zA-Z0-9_]*))`)
+	// NonAlphanumRegexp matches the runs of characters not allowed in names.
+	NonAlphanumRegexp = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+)
+
+// ComposeVariable is a compose variable matched by ComposeVariableRegexp.
+// Default replaces the variable when it is unset, or also empty when
+// DefaultIfEmpty, as ${NAME:-default} does.
+type ComposeVariable struct {
+	Name           string
+	Default        string
+	HasDefault     bool
+	DefaultIfEmpty bool
+}
+
+// ParseComposeVariable parses a variable matched by ComposeVariableRegexp.
+func ParseComposeVariable(variable string) ComposeVariable {
+	match := ComposeVariableRegexp.FindStringSubmatch(variable)
+	if match == nil {
+		return ComposeVariable{}
+	}
+	if match[4] != "" {
+		return ComposeVariable{Name: match[4]}
+	}
+	return ComposeVariable{
+		Name:           match[1],
+		Default:        match[3],
+		HasDefault:     match[2] != "",
+		DefaultIfEmpty: match[2] == ":-",
+	}
+}
+
+// Resolve applies the default of the variable to its value, set tells
+// whether the variable has one.
+func (v ComposeVariable) Resolve(value string, set bool) (string, bool) {
+	if v.HasDefault && (!set || v.DefaultIfEmpty && value == "") {
+		return v.Default, true
+	}
+	return value, set
+}
+
+// NamespaceQuestion is the question setting the namespace of the objects,
+// the CLI adds it to the catalog.
+var NamespaceQuestion = RancherQuestion{
+	Variable:    "NAMESPACE",
+	Label:       "Kubernetes Namespace",
+	Description: "Make sure the Namespace exists or you will not be able to see the service",
+	Type:        "string",
+	Default:     "default",
+	Required:    true,
+}
+
+// answersLookup resolves compose variables to the answers, then to the
+// environment.
+type answersLookup struct {
+	answers map[string]string
+}
+
+func (l answersLookup) Lookup(key, serviceName string, config *config.ServiceConfig) []string {
+	if value, ok := l.answers[key]; ok {
+		return []string{key + "=" + value}
+	}
+	if value, ok := os.LookupEnv(key); ok {
+		return []string{key + "=" + value}
+	}
+	return nil
+}
+
+// ValidateAnswer checks an answer against the type and limits of its
+// question and returns it normalized.
+func ValidateAnswer(question RancherQuestion, answer string) (string, error) {
+	if answer == "" {
+		if question.Required {
+			return "", fmt.Errorf("an answer is required")
+		}
+		return answer, nil
+	}
+	switch question.Type {
+	case "int":
+		number, err := strconv.Atoi(answer)
+		if err != nil {
+			return "", fmt.Errorf("%q is not an integer", answer)
+		}
+		if question.Min != nil && number < *question.Min {
+			return "", fmt.Errorf("%d is below the minimum of %d", number, *question.Min)
+		}
+		if question.Max != nil && number > *question.Max {
+			return "", fmt.Errorf("%d is above the maximum of %d", number, *question.Max)
+		}
+		return strconv.Itoa(number), nil
+	case "float":
+		if _, err := strconv.ParseFloat(answer, 64); err != nil {
+			return "", fmt.Errorf("%q is not a number", answer)
+		}
+	case "boolean":
+		value, err := strconv.ParseBool(answer)
+		if err != nil {
+			return "", fmt.Errorf("%q is not true or false", answer)
+		}
+		return strconv.FormatBool(value), nil
+	case "enum":
+		if !containsString(question.Options, answer) {
+			return "", fmt.Errorf("%q is not one of %s", answer, strings.Join(question.Options, ", "))
+		}
+	default:
+		// string, password, multiline, service and certificate answers
+		// are free text.
+		if question.MinLength != nil && len(answer) < *question.MinLength {
+			return "", fmt.Errorf("the answer is shorter than %d characters", *question.MinLength)
+		}
+		if question.MaxLength != nil && len(answer) > *question.MaxLength {
+			return "", fmt.Errorf("the answer is longer than %d characters", *question.MaxLength)
+		}
+	}
+	return answer, nil
+}
+
+// escapedVariableRegexp matches the $$ escapes along with the compose
+// variables, so that the variables they escape are left alone.
+var escapedVariableRegexp = regexp.MustCompile(`\$\$|` + ComposeVariableRegexp.String())
+
+// answerFile substitutes the answers into the values of a YAML file, which
+// is parsed first so that answers cannot change its structure, and returns
+// the file to unmarshal.
+func answerFile(data []byte, answers map[string]string) ([]byte, error) {
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(substituteAnswers(document, answers))
+}
+
+// substituteAnswers replaces the compose variables of the strings of a
+// parsed file with their answer, like the compose files are interpolated:
+// $$ escapes a $ and unanswered variables are left as they are. A string
+// made of a single variable takes the type of a number or boolean answer.
+func substituteAnswers(value interface{}, answers map[string]string) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		for key, item := range value {
+			value[key] = substituteAnswers(item, answers)
+		}
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = substituteAnswers(item, answers)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = substituteAnswers(item, answers)
+		}
+	case string:
+		if match := ComposeVariableRegexp.FindString(value); match == value {
+			variable := ParseComposeVariable(value)
+			if answer, ok := answers[variable.Name]; ok {
+				answer, _ = variable.Resolve(answer, true)
+				return scalarAnswer(answer)
+			}
+			return value
+		}
+		return escapedVariableRegexp.ReplaceAllStringFunc(value, func(reference string) string {
+			if reference == "$$" {
+				return "$"
+			}
+			variable := ParseComposeVariable(reference)
+			if answer, ok := answers[variable.Name]; ok {
+				answer, _ = variable.Resolve(answer, true)
+				return answer
+			}
+			return reference
+		})
+	}
+	return value
+}
+
+func scalarAnswer(answer string) interface{} {
+	if number, err := strconv.Atoi(answer); err == nil {
+		return number
+	}
+	if answer == "true" || answer == "false" {
+		return answer == "true"
+	}
+	return answer
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"k8s.io/kubernetes/pkg/api"
+
+	"gopkg.in/yaml.v2"
+)
+
+// healthCheck is the healthcheck section of a compose service.
+type healthCheck struct {
+	Test        interface{} `yaml:"test"`
+	Interval    string      `yaml:"interval"`
+	Timeout     string      `yaml:"timeout"`
+	Retries     int32       `yaml:"retries"`
+	StartPeriod string      `yaml:"start_period"`
+	Disable     bool        `yaml:"disable"`
+}
+
+// parseHealthChecks reads the healthcheck of the services of the compose
+// files, later files override the whole section of the earlier ones. The
+// sections that cannot be read are returned as errors of their service.
+func (c *Converter) parseHealthChecks(composeFiles ...string) (map[string]healthCheck, Errors) {
+	healthChecks := map[string]healthCheck{}
+	var errors Errors
+	for _, composeFile := range composeFiles {
+		file, err := ioutil.ReadFile(composeFile)
+		if err != nil {
+			fail("Failed to read %s: %v", composeFile, err)
+		}
+		if c.options.Answers != nil {
+			if file, err = answerFile(file, c.options.Answers); err != nil {
+				fail("Failed to parse %s: %v", composeFile, err)
+			}
+		}
+		var f struct {
+			Version  string                            `yaml:"version"`
+			Services map[string]map[string]interface{} `yaml:"services"`
+		}
+		if err := yaml.Unmarshal(file, &f); err != nil {
+			fail("Failed to parse %s: %v", composeFile, err)
+		}
+		services := f.Services
+		if f.Version == "" {
+			// Version 1 files list the services at the top level.
+			if err := yaml.Unmarshal(file, &services); err != nil {
+				fail("Failed to parse %s: %v", composeFile, err)
+			}
+		}
+		for name, service := range services {
+			section, ok := service["healthcheck"]
+			if !ok {
+				continue
+			}
+			data, err := yaml.Marshal(section)
+			if err == nil {
+				var check healthCheck
+				if err = yaml.Unmarshal(data, &check); err == nil {
+					healthChecks[name] = check
+					continue
+				}
+			}
+			errors = append(errors, &Error{Service: name, Key: "healthcheck", Message: err.Error()})
+		}
+	}
+	return healthChecks, errors
+}
+
+// healthCheckCommand returns the command run by the healthcheck test, or nil
+// if the test disables the image healthcheck.
+func (c *conversion) healthCheckCommand(name string, test interface{}) []string {
+	switch test := test.(type) {
+	case string:
+		// A plain string is run by the shell, like CMD-SHELL.
+		return []string{"/bin/sh", "-c", test}
+	case []interface{}:
+		var args []string
+		for _, arg := range test {
+			args = append(args, fmt.Sprint(arg))
+		}
+		if len(args) == 0 {
+			break
+		}
+		switch args[0] {
+		case "NONE":
+			return nil
+		case "CMD":
+			if len(args) > 1 {
+				return args[1:]
+			}
+		case "CMD-SHELL":
+			if len(args) == 2 {
+				return []string{"/bin/sh", "-c", args[1]}
+			}
+		}
+	}
+	c.errorf(name, "healthcheck.test", "invalid test %v", test)
+	return nil
+}
+
+// healthCheckSeconds parses a compose duration, rounding it up to whole
+// seconds since probes do not go below.
+func (c *conversion) healthCheckSeconds(name string, key string, value string, defaultSeconds int32) int32 {
+	if value == "" {
+		return defaultSeconds
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		c.errorf(name, "healthcheck."+key, "invalid duration %s: %v", value, err)
+		return defaultSeconds
+	}
+	return int32((duration + time.Second - 1) / time.Second)
+}
+
+// configureComposeHealthCheck returns the exec probe running the compose
+// healthcheck of the service, or nil if it has none or disables it. Unset
+// values default to the ones of the Docker engine.
+func (c *conversion) configureComposeHealthCheck(name string) *api.Probe {
+	check, ok := c.healthChecks[name]
+	if !ok || check.Disable {
+		return nil
+	}
+	if check.Test == nil {
+		// The section only overrides the settings of the HEALTHCHECK of
+		// the image, which Kubernetes does not read.
+		c.warnf("service %s: the healthcheck has no test, the HEALTHCHECK of the image is not turned into a probe", name)
+		return nil
+	}
+	command := c.healthCheckCommand(name, check.Test)
+	if command == nil {
+		return nil
+	}
+	retries := check.Retries
+	if retries == 0 {
+		retries = 3
+	}
+	return &api.Probe{
+		Handler: api.Handler{
+			Exec: &api.ExecAction{Command: command},
+		},
+		InitialDelaySeconds: c.healthCheckSeconds(name, "start_period", check.StartPeriod, 0),
+		PeriodSeconds:       c.healthCheckSeconds(name, "interval", check.Interval, 30),
+		TimeoutSeconds:      c.healthCheckSeconds(name, "timeout", check.Timeout, 30),
+		FailureThreshold:    retries,
+	}
+}
+
+// configureProbes returns the liveness and readiness probes of the service.
+// The rancher-compose health_check takes precedence over the compose
+// healthcheck, which is used for the probes selected by HealthCheckProbes.
+func (c *conversion) configureProbes(name string, rancherCompose *RancherCompose) (*api.Probe, *api.Probe) {
+	if liveness, readiness := c.configureHealthCheck(name, rancherCompose); readiness != nil {
+		return liveness, readiness
+	}
+	probe := c.configureComposeHealthCheck(name)
+	if probe == nil {
+		return nil, nil
+	}
+	switch c.options.HealthCheckProbes {
+	case "liveness":
+		return probe, nil
+	case "readiness":
+		return nil, probe
+	}
+	readiness := *probe
+	return probe, &readiness
+}
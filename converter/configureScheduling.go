@@ -0,0 +1,208 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/docker/libcompose/config"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+)
+
+// schedulerLabelPrefix starts the Rancher scheduling rules. Their keys are
+// not valid Kubernetes labels, they are translated and never copied.
+const schedulerLabelPrefix = "io.rancher.scheduler.affinity:"
+
+// Rancher schedules on hosts, Kubernetes on nodes: pod affinities are
+// evaluated per node, and soft rules get the highest weight.
+const (
+	hostTopologyKey = "kubernetes.io/hostname"
+	softRuleWeight  = 100
+)
+
+// stackServiceLabels are the container labels Rancher sets to the
+// <stack>/<service> running the container.
+var stackServiceLabels = []string{"io.rancher.stack_service.name", "io.rancher.stack.service.name"}
+
+// schedulingRule is a key=value condition of a Rancher scheduling label.
+type schedulingRule struct {
+	key   string
+	value string
+}
+
+// parseSchedulingRules splits the comma separated key=value conditions of a
+// scheduling label.
+func (c *conversion) parseSchedulingRules(name string, label string, value string) []schedulingRule {
+	var rules []schedulingRule
+	for _, condition := range strings.Split(value, ",") {
+		condition = strings.TrimSpace(condition)
+		if condition == "" {
+			continue
+		}
+		parts := strings.SplitN(condition, "=", 2)
+		if len(parts) != 2 {
+			c.errorf(name, "labels."+label, "invalid condition %s, expected key=value", condition)
+			continue
+		}
+		rule := schedulingRule{key: strings.TrimSpace(parts[0]), value: strings.TrimSpace(parts[1])}
+		for _, stackServiceLabel := range stackServiceLabels {
+			if rule.key == stackServiceLabel {
+				// Pods carry the short name of their service.
+				service := rule.value[strings.LastIndex(rule.value, "/")+1:]
+				if len(service) > 24 {
+					service = service[0:24]
+				}
+				rule = schedulingRule{key: "service", value: service}
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func nodeSelectorTerm(rules []schedulingRule, operator api.NodeSelectorOperator) api.NodeSelectorTerm {
+	var term api.NodeSelectorTerm
+	for _, rule := range rules {
+		term.MatchExpressions = append(term.MatchExpressions, api.NodeSelectorRequirement{
+			Key:      rule.key,
+			Operator: operator,
+			Values:   []string{rule.value},
+		})
+	}
+	return term
+}
+
+func podAffinityTerm(rule schedulingRule) api.PodAffinityTerm {
+	return api.PodAffinityTerm{
+		LabelSelector: &unversioned.LabelSelector{
+			MatchLabels: map[string]string{rule.key: rule.value},
+		},
+		TopologyKey: hostTopologyKey,
+	}
+}
+
+// configureScheduling translates the Rancher scheduling labels of the
+// service into a node selector and the affinity of the pod. Host labels
+// select nodes, container labels select pods, _ne rules are negated and
+// _soft rules are preferences.
+func (c *conversion) configureScheduling(name string, service *config.ServiceConfig, template *api.PodTemplateSpec) {
+	var labels []string
+	for label := range service.Labels {
+		if strings.HasPrefix(label, schedulerLabelPrefix) {
+			labels = append(labels, label)
+		}
+	}
+	if len(labels) == 0 {
+		return
+	}
+	sort.Strings(labels)
+
+	nodeAffinity := &api.NodeAffinity{}
+	podAffinity := &api.PodAffinity{}
+	podAntiAffinity := &api.PodAntiAffinity{}
+	var required []schedulingRule
+	for _, label := range labels {
+		rules := c.parseSchedulingRules(name, label, service.Labels[label])
+		switch strings.TrimPrefix(label, schedulerLabelPrefix) {
+		case "host_label":
+			if template.Spec.NodeSelector == nil {
+				template.Spec.NodeSelector = map[string]string{}
+			}
+			for _, rule := range rules {
+				template.Spec.NodeSelector[rule.key] = rule.value
+			}
+		case "host_label_ne":
+			required = append(required, rules...)
+		case "host_label_soft":
+			for _, rule := range rules {
+				nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution, api.PreferredSchedulingTerm{
+					Weight:     softRuleWeight,
+					Preference: nodeSelectorTerm([]schedulingRule{rule}, api.NodeSelectorOpIn),
+				})
+			}
+		case "host_label_soft_ne":
+			for _, rule := range rules {
+				nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution, api.PreferredSchedulingTerm{
+					Weight:     softRuleWeight,
+					Preference: nodeSelectorTerm([]schedulingRule{rule}, api.NodeSelectorOpNotIn),
+				})
+			}
+		case "container_label":
+			for _, rule := range rules {
+				podAffinity.RequiredDuringSchedulingIgnoredDuringExecution = append(podAffinity.RequiredDuringSchedulingIgnoredDuringExecution, podAffinityTerm(rule))
+			}
+		case "container_label_ne":
+			for _, rule := range rules {
+				podAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution = append(podAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution, podAffinityTerm(rule))
+			}
+		case "container_label_soft":
+			for _, rule := range rules {
+				podAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(podAffinity.PreferredDuringSchedulingIgnoredDuringExecution, api.WeightedPodAffinityTerm{
+					Weight:          softRuleWeight,
+					PodAffinityTerm: podAffinityTerm(rule),
+				})
+			}
+		case "container_label_soft_ne":
+			for _, rule := range rules {
+				podAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(podAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution, api.WeightedPodAffinityTerm{
+					Weight:          softRuleWeight,
+					PodAffinityTerm: podAffinityTerm(rule),
+				})
+			}
+		default:
+			c.warnf("service %s: scheduling label %s is not supported, leaving it out", name, label)
+		}
+	}
+	if len(required) > 0 {
+		// The expressions of a term must all match.
+		nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &api.NodeSelector{
+			NodeSelectorTerms: []api.NodeSelectorTerm{nodeSelectorTerm(required, api.NodeSelectorOpNotIn)},
+		}
+	}
+
+	affinity := api.Affinity{}
+	if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil || len(nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution) > 0 {
+		affinity.NodeAffinity = nodeAffinity
+	}
+	if len(podAffinity.RequiredDuringSchedulingIgnoredDuringExecution) > 0 || len(podAffinity.PreferredDuringSchedulingIgnoredDuringExecution) > 0 {
+		affinity.PodAffinity = podAffinity
+	}
+	if len(podAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution) > 0 || len(podAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution) > 0 {
+		affinity.PodAntiAffinity = podAntiAffinity
+	}
+	if affinity.NodeAffinity == nil && affinity.PodAffinity == nil && affinity.PodAntiAffinity == nil {
+		return
+	}
+	// The vendored types only know the affinity annotation,
+	// adjustPodAnnotations turns it into a field on newer releases.
+	data, err := json.Marshal(affinity)
+	if err == nil {
+		// Drop the null namespaces of the pod affinity terms.
+		var versioned interface{}
+		json.Unmarshal(data, &versioned)
+		pruneNulls(versioned)
+		data, err = json.Marshal(versioned)
+	}
+	if err != nil {
+		fail("Failed to marshal the affinity of service %s: %v", name, err)
+	}
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[api.AffinityAnnotationKey] = string(data)
+}
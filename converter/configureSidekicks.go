@@ -0,0 +1,146 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/docker/libcompose/project"
+
+	"k8s.io/kubernetes/pkg/api"
+)
+
+// Rancher labels translated into the pod template. They are not copied to
+// the generated objects.
+const (
+	sidekicksLabel = "io.rancher.sidekicks"
+	startOnceLabel = "io.rancher.container.start_once"
+)
+
+// schedulingLabelPrefix starts the Rancher labels placing the containers of
+// a service, affinity rules and global services among them.
+const schedulingLabelPrefix = "io.rancher.scheduler."
+
+// sidekickPrimaries maps every sidekick of the project to the service
+// declaring it in its io.rancher.sidekicks label.
+func (c *conversion) sidekickPrimaries(dockerCompose *project.Project) map[string]string {
+	primaries := map[string]string{}
+	for _, name := range dockerCompose.ServiceConfigs.Keys() {
+		service, _ := dockerCompose.ServiceConfigs.Get(name)
+		for _, sidekick := range strings.Split(service.Labels[sidekicksLabel], ",") {
+			sidekick = strings.TrimSpace(sidekick)
+			if sidekick == "" {
+				continue
+			}
+			if _, ok := dockerCompose.ServiceConfigs.Get(sidekick); !ok {
+				c.errorf(name, "labels."+sidekicksLabel, "unknown sidekick %s", sidekick)
+				continue
+			}
+			if primary, ok := primaries[sidekick]; ok && primary != name {
+				c.errorf(name, "labels."+sidekicksLabel, "sidekick %s is already declared by %s", sidekick, primary)
+				continue
+			}
+			primaries[sidekick] = name
+		}
+	}
+	return primaries
+}
+
+// configureSidekicks adds the sidekicks of the service to its pod template.
+// Sidekicks started once run as init containers, the others next to the
+// primary container. Containers share the volumes named by volumes_from.
+// The pod is scheduled and restarted as the primary service says, the
+// scheduling labels and restart policy of the sidekicks are left out.
+func (c *conversion) configureSidekicks(name string, template *api.PodTemplateSpec, dockerCompose *project.Project, primaries map[string]string, rancherCompose *RancherCompose) {
+	primary, _ := dockerCompose.ServiceConfigs.Get(name)
+	services := map[string]string{template.Spec.Containers[0].Name: name}
+	mounts := map[string][]api.VolumeMount{name: template.Spec.Containers[0].VolumeMounts}
+	for _, sidekick := range dockerCompose.ServiceConfigs.Keys() {
+		if primaries[sidekick] != name {
+			continue
+		}
+		service, _ := dockerCompose.ServiceConfigs.Get(sidekick)
+		var labels []string
+		for label := range service.Labels {
+			if strings.HasPrefix(label, schedulingLabelPrefix) {
+				labels = append(labels, label)
+			}
+		}
+		sort.Strings(labels)
+		for _, label := range labels {
+			c.warnf("service %s: sidekick pods are scheduled by their primary service %s, leaving the %s label out", sidekick, name, label)
+		}
+		if service.Restart != "" && service.Restart != primary.Restart {
+			c.warnf("service %s: sidekick pods restart as their primary service %s, leaving restart %q out", sidekick, name, service.Restart)
+		}
+		shortName := sidekick
+		if len(sidekick) > 24 {
+			shortName = sidekick[0:24]
+		}
+		sidekickTemplate := c.createPodTemplate(sidekick, shortName, service, rancherCompose)
+		container := sidekickTemplate.Spec.Containers[0]
+		services[container.Name] = sidekick
+		mounts[sidekick] = container.VolumeMounts
+		for _, volume := range sidekickTemplate.Spec.Volumes {
+			if !hasVolume(template.Spec.Volumes, volume.Name) {
+				template.Spec.Volumes = append(template.Spec.Volumes, volume)
+			}
+		}
+		if service.Labels[startOnceLabel] == "true" {
+			template.Spec.InitContainers = append(template.Spec.InitContainers, container)
+		} else {
+			template.Spec.Containers = append(template.Spec.Containers, container)
+		}
+	}
+	if len(services) == 1 {
+		return
+	}
+	for _, containers := range [][]api.Container{template.Spec.Containers, template.Spec.InitContainers} {
+		for i := range containers {
+			serviceName := services[containers[i].Name]
+			service, _ := dockerCompose.ServiceConfigs.Get(serviceName)
+			for _, from := range service.VolumesFrom {
+				from = strings.Split(from, ":")[0]
+				if _, ok := mounts[from]; !ok {
+					c.warnf("service %s: volumes_from %s is not in the same pod, leaving it out", serviceName, from)
+					continue
+				}
+				for _, mount := range mounts[from] {
+					if !hasVolumeMount(containers[i].VolumeMounts, mount.MountPath) {
+						containers[i].VolumeMounts = append(containers[i].VolumeMounts, mount)
+					}
+				}
+			}
+		}
+	}
+}
+
+func hasVolume(volumes []api.Volume, name string) bool {
+	for _, volume := range volumes {
+		if volume.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasVolumeMount(mounts []api.VolumeMount, mountPath string) bool {
+	for _, mount := range mounts {
+		if mount.MountPath == mountPath {
+			return true
+		}
+	}
+	return false
+}
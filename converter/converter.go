@@ -0,0 +1,201 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package converter converts docker-compose and rancher-compose projects to
+// Kubernetes objects.
+//
+//	c, err := converter.New(converter.Options{KubeVersion: "1.6"})
+//	if err != nil {
+//		return err
+//	}
+//	p, err := c.LoadProject("docker-compose.yml")
+//	if err != nil {
+//		return err
+//	}
+//	if p.Rancher, err = c.LoadRancherCompose("rancher-compose.yml"); err != nil {
+//		return err
+//	}
+//	result, err := c.Convert(p)
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/libcompose/config"
+	"github.com/docker/libcompose/project"
+)
+
+// Options configure a Converter.
+type Options struct {
+	// KubeVersion is the Kubernetes release the objects target, 1.1 when
+	// empty.
+	KubeVersion string
+	// HealthCheckProbes selects the probes made from compose healthchecks:
+	// liveness, readiness or both, the default.
+	HealthCheckProbes string
+	// Answers answer the catalog questions. When set the compose files are
+	// interpolated with them, and NAMESPACE sets the namespace of the
+	// objects.
+	Answers map[string]string
+	// SkipFailingServices leaves the services with errors out of the
+	// result instead of failing the conversion.
+	SkipFailingServices bool
+	// Strict makes the compose keys dropped by the conversion errors of
+	// their service.
+	Strict bool
+	// Transformers run, in order, over the objects of a conversion.
+	Transformers []Transformer
+}
+
+// Converter converts compose projects with the same options. It is safe
+// for concurrent use.
+type Converter struct {
+	options     Options
+	targetMinor int
+}
+
+// Project is a compose project and the rancher-compose file of its stack,
+// Rancher is nil when it has none.
+type Project struct {
+	Compose *project.Project
+	Rancher *RancherCompose
+
+	// healthChecks holds the healthcheck sections the vendored libcompose
+	// does not know, read by LoadProject along with their errors.
+	healthChecks      map[string]healthCheck
+	healthCheckErrors Errors
+	extensions        projectExtensions
+}
+
+// Object is a Kubernetes object converted from a compose service.
+type Object struct {
+	// Name and Suffix name the object and its file, <Name>-<Suffix>.yml.
+	Name   string
+	Suffix string
+	// Service is the compose service the object comes from.
+	Service *config.ServiceConfig
+	// Versioned is the object adjusted to the target Kubernetes release, as
+	// changed by the transformers and written out.
+	Versioned map[string]interface{}
+}
+
+// Result holds the objects of a conversion, the warnings about what could
+// not be converted as is, the errors of the services left out and the
+// report of what became of the compose keys.
+type Result struct {
+	Objects  []Object
+	Warnings []string
+	Errors   Errors
+	Report   Report
+}
+
+// New returns a Converter with the given options.
+func New(options Options) (*Converter, error) {
+	if options.KubeVersion == "" {
+		options.KubeVersion = "1.1"
+	}
+	if options.HealthCheckProbes == "" {
+		options.HealthCheckProbes = "both"
+	}
+	minor, err := parseKubeVersion(options.KubeVersion)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Kubernetes version %s: %v", options.KubeVersion, err)
+	}
+	switch options.HealthCheckProbes {
+	case "liveness", "readiness", "both":
+	default:
+		return nil, fmt.Errorf("Unknown probe kind %s, expected liveness, readiness or both", options.HealthCheckProbes)
+	}
+	return &Converter{options: options, targetMinor: minor}, nil
+}
+
+// conversion holds the state of a call to Convert.
+type conversion struct {
+	options      Options
+	targetMinor  int
+	healthChecks map[string]healthCheck
+	extensions   projectExtensions
+	warnings     []string
+	errors       Errors
+	// failed holds the services with errors.
+	failed map[string]bool
+}
+
+func (c *conversion) warnf(format string, args ...interface{}) {
+	c.warnings = append(c.warnings, fmt.Sprintf(format, args...))
+}
+
+// LoadProject parses a compose project, later files override the services
+// of the earlier ones.
+func (c *Converter) LoadProject(composeFiles ...string) (p *Project, err error) {
+	defer recoverError(&err)
+	context := &project.Context{
+		ProjectName:  "kube",
+		ComposeFiles: composeFiles,
+	}
+	parseOptions := &config.ParseOptions{}
+	if c.options.Answers != nil {
+		context.EnvironmentLookup = answersLookup{c.options.Answers}
+		parseOptions.Interpolate = true
+	}
+	compose := project.NewProject(context, nil, parseOptions)
+	if err := compose.Parse(); err != nil {
+		return nil, fmt.Errorf("Failed to parse the compose project from %s: %v", strings.Join(composeFiles, ", "), err)
+	}
+	if compose.ServiceConfigs == nil {
+		return nil, fmt.Errorf("No service config found, aborting")
+	}
+	p = &Project{Compose: compose, extensions: c.parseExtensions(composeFiles...)}
+	for _, name := range p.extensions.topLevel {
+		compose.ServiceConfigs.Remove(name)
+	}
+	p.healthChecks, p.healthCheckErrors = c.parseHealthChecks(composeFiles...)
+	return p, nil
+}
+
+// Convert converts every service of the project to its Kubernetes objects.
+// The errors of all the services are returned at once, as Errors, along
+// with the result holding the objects of the other services. With
+// SkipFailingServices they are only listed in the result.
+func (c *Converter) Convert(p *Project) (result *Result, err error) {
+	defer recoverError(&err)
+	conversion := &conversion{
+		options:      c.options,
+		targetMinor:  c.targetMinor,
+		healthChecks: p.healthChecks,
+		extensions:   p.extensions,
+		failed:       map[string]bool{},
+	}
+	for _, err := range p.healthCheckErrors {
+		conversion.addError(err)
+	}
+	rancherCompose := p.Rancher
+	if rancherCompose == nil {
+		rancherCompose = &RancherCompose{}
+	}
+	report := conversion.reportProject(p.Compose)
+	objects := conversion.convertDockerCompose(p.Compose, rancherCompose)
+	if objects, err = conversion.transform(objects, c.options.Transformers); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(conversion.errors, func(i, j int) bool {
+		return conversion.errors[i].Service < conversion.errors[j].Service
+	})
+	result = &Result{Objects: objects, Warnings: conversion.warnings, Errors: conversion.errors, Report: report}
+	if len(conversion.errors) > 0 && !c.options.SkipFailingServices {
+		return result, conversion.errors
+	}
+	return result, nil
+}
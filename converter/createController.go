@@ -0,0 +1,173 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"strings"
+
+	"github.com/docker/libcompose/config"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/apis/apps"
+	"k8s.io/kubernetes/pkg/apis/batch"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+// Labels starting with controllerLabelPrefix configure compose2kube and are
+// not copied to the generated objects.
+const (
+	controllerLabelPrefix = "compose2kube."
+	controllerLabel       = "compose2kube.controller"
+	scheduleLabel         = "compose2kube.schedule"
+)
+
+var controllerKinds = map[string]string{
+	"replicationcontroller": "ReplicationController",
+	"deployment":            "Deployment",
+	"daemonset":             "DaemonSet",
+	"statefulset":           "StatefulSet",
+	"cronjob":               "CronJob",
+}
+
+// controllerFallbacks is the kind used in place of a controller the target
+// release does not serve.
+var controllerFallbacks = map[string]string{
+	"Deployment":  "ReplicationController",
+	"DaemonSet":   "ReplicationController",
+	"StatefulSet": "Deployment",
+	"CronJob":     "Deployment",
+}
+
+// controllerKind returns the kind of controller to create for the service.
+// Deployments are used whenever the target release serves them, the
+// compose2kube.controller label asks for another kind.
+func (c *conversion) controllerKind(name string, service *config.ServiceConfig) string {
+	requested, ok := service.Labels[controllerLabel]
+	kind := controllerKinds[strings.ToLower(requested)]
+	if ok && kind == "" {
+		c.errorf(name, "labels."+controllerLabel, "unknown controller %s", requested)
+	}
+	if kind == "" {
+		if c.kindAvailable("Deployment") {
+			return "Deployment"
+		}
+		return "ReplicationController"
+	}
+	fallback := kind
+	for !c.kindAvailable(fallback) {
+		fallback = controllerFallbacks[fallback]
+	}
+	if fallback != kind {
+		c.warnf("%s is not available on Kubernetes 1.%d, service %s uses a %s instead", kind, c.targetMinor, name, fallback)
+	}
+	return fallback
+}
+
+// createController returns the controller running the pod template of the
+// service and the suffix of the file it is written to.
+func (c *conversion) createController(name string, shortName string, service *config.ServiceConfig, template *api.PodTemplateSpec, rancherCompose *RancherCompose) (runtime.Object, string) {
+	kind := c.controllerKind(name, service)
+	if service := rancherCompose.service(name); service != nil && service.UpgradeStrategy != nil && kind != "Deployment" {
+		c.warnf("service %s: upgrade_strategy only applies to Deployments, leaving it out of the %s", name, kind)
+	}
+	switch kind {
+	case "Deployment":
+		return createDeployment(name, shortName, service, template, rancherCompose), "deploy"
+	case "DaemonSet":
+		return createDaemonSet(shortName, service, template), "ds"
+	case "StatefulSet":
+		return createStatefulSet(name, shortName, service, template, rancherCompose), "sts"
+	case "CronJob":
+		return createCronJob(name, shortName, service, template), "cj"
+	}
+	return createReplicationController(name, shortName, service, template, rancherCompose), "rc"
+}
+
+func controllerMeta(shortName string, service *config.ServiceConfig) api.ObjectMeta {
+	return api.ObjectMeta{
+		Name:      shortName,
+		Namespace: "${NAMESPACE}",
+		Labels:    configureLabels(shortName, service),
+	}
+}
+
+func serviceSelector(shortName string) *unversioned.LabelSelector {
+	return &unversioned.LabelSelector{
+		MatchLabels: map[string]string{"service": shortName},
+	}
+}
+
+func createDeployment(name string, shortName string, service *config.ServiceConfig, template *api.PodTemplateSpec, rancherCompose *RancherCompose) *extensions.Deployment {
+	strategy, minReadySeconds := configureUpgradeStrategy(name, rancherCompose)
+	return &extensions.Deployment{
+		ObjectMeta: controllerMeta(shortName, service),
+		Spec: extensions.DeploymentSpec{
+			Replicas:        configureScale(name, rancherCompose),
+			Selector:        serviceSelector(shortName),
+			Template:        *template,
+			Strategy:        strategy,
+			MinReadySeconds: minReadySeconds,
+		},
+	}
+}
+
+func createDaemonSet(shortName string, service *config.ServiceConfig, template *api.PodTemplateSpec) *extensions.DaemonSet {
+	return &extensions.DaemonSet{
+		ObjectMeta: controllerMeta(shortName, service),
+		Spec: extensions.DaemonSetSpec{
+			Selector: serviceSelector(shortName),
+			Template: *template,
+		},
+	}
+}
+
+// createStatefulSet builds the object as a PetSet, the vendored name of
+// StatefulSets. adjustForKubeVersion renames it on output.
+func createStatefulSet(name string, shortName string, service *config.ServiceConfig, template *api.PodTemplateSpec, rancherCompose *RancherCompose) *apps.PetSet {
+	return &apps.PetSet{
+		ObjectMeta: controllerMeta(shortName, service),
+		Spec: apps.PetSetSpec{
+			Replicas:    int(configureScale(name, rancherCompose)),
+			Selector:    serviceSelector(shortName),
+			Template:    *template,
+			ServiceName: shortName,
+		},
+	}
+}
+
+// createCronJob builds the object as a ScheduledJob, the vendored name of
+// CronJobs. adjustForKubeVersion renames it on output.
+func createCronJob(name string, shortName string, service *config.ServiceConfig, template *api.PodTemplateSpec) *batch.ScheduledJob {
+	schedule, ok := service.Labels[scheduleLabel]
+	if !ok {
+		failKey("labels", "missing %s label for a cron job", scheduleLabel)
+	}
+	// Jobs only accept pods that stop restarting once they succeed.
+	if template.Spec.RestartPolicy == api.RestartPolicyAlways {
+		template.Spec.RestartPolicy = api.RestartPolicyOnFailure
+	}
+	return &batch.ScheduledJob{
+		ObjectMeta: controllerMeta(shortName, service),
+		Spec: batch.ScheduledJobSpec{
+			Schedule: schedule,
+			JobTemplate: batch.JobTemplateSpec{
+				Spec: batch.JobSpec{
+					Template: *template,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/libcompose/config"
+	"github.com/docker/libcompose/project"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/util/intstr"
+)
+
+// Images of the Rancher services that only exist in its DNS.
+const (
+	externalServiceImage = "rancher/external-service"
+	dnsServiceImage      = "rancher/dns-service"
+)
+
+// aliasLabelPrefix starts the pod label shared by the services of a DNS
+// alias linking several of them.
+const aliasLabelPrefix = "alias.compose2kube/"
+
+func isExternalService(service *config.ServiceConfig) bool {
+	return strings.HasPrefix(service.Image, externalServiceImage)
+}
+
+func isDNSService(service *config.ServiceConfig) bool {
+	return strings.HasPrefix(service.Image, dnsServiceImage)
+}
+
+// linkedServices returns the services linked by a service, without the
+// stack and the alias of the links.
+func linkedServices(service *config.ServiceConfig) []string {
+	var linked []string
+	for _, link := range service.Links {
+		target := strings.Split(link, ":")[0]
+		linked = append(linked, target[strings.LastIndex(target, "/")+1:])
+	}
+	return linked
+}
+
+// aliasLabels returns, for every service linked by a DNS alias of several
+// services, the labels its pods carry so the alias can select them.
+func aliasLabels(dockerCompose *project.Project) map[string]map[string]string {
+	labels := map[string]map[string]string{}
+	for _, name := range dockerCompose.ServiceConfigs.Keys() {
+		service, _ := dockerCompose.ServiceConfigs.Get(name)
+		linked := linkedServices(service)
+		if !isDNSService(service) || len(linked) < 2 {
+			continue
+		}
+		for _, target := range linked {
+			if labels[target] == nil {
+				labels[target] = map[string]string{}
+			}
+			labels[target][aliasLabelPrefix+shortServiceName(name)] = "true"
+		}
+	}
+	return labels
+}
+
+func shortServiceName(name string) string {
+	if len(name) > 24 {
+		return name[0:24]
+	}
+	return name
+}
+
+// createExternalService converts a Rancher external service. Hostnames
+// become ExternalName Services, IPs a Service without selector and the
+// Endpoints it sends its traffic to.
+func (c *conversion) createExternalService(name string, shortName string, service *config.ServiceConfig, rancherCompose *RancherCompose) []Object {
+	external := rancherCompose.service(name)
+	if external == nil {
+		external = &RancherService{}
+	}
+	meta := api.ObjectMeta{
+		Name:      shortName,
+		Namespace: "${NAMESPACE}",
+		Labels:    configureLabels(shortName, service),
+	}
+	var ports []api.ServicePort
+	var endpointPorts []api.EndpointPort
+	for _, port := range c.configurePorts(name, service) {
+		portName := fmt.Sprintf("port-%d", port.ContainerPort)
+		ports = append(ports, api.ServicePort{Name: portName, Port: port.ContainerPort, TargetPort: intstr.FromInt(int(port.ContainerPort))})
+		endpointPorts = append(endpointPorts, api.EndpointPort{Name: portName, Port: port.ContainerPort, Protocol: api.ProtocolTCP})
+	}
+
+	if hostname := external.Hostname; hostname != "" {
+		if c.targetMinor < 4 {
+			c.warnf("ExternalName Services are not supported on Kubernetes 1.%d, service %s needs a newer release", c.targetMinor, name)
+		}
+		srv := c.convertObject(shortName, "srv", service, &api.Service{
+			ObjectMeta: meta,
+			Spec:       api.ServiceSpec{Ports: ports},
+		})
+		// The vendored types predate ExternalName Services.
+		spec := srv.Versioned["spec"].(map[string]interface{})
+		spec["type"] = "ExternalName"
+		spec["externalName"] = hostname
+		return []Object{srv}
+	}
+
+	if len(external.ExternalIPs) == 0 {
+		rancherCompose.fatalf(rancherCompose.servicePath(name, "external_ips"), "an external service needs external_ips or hostname")
+	}
+	var addresses []api.EndpointAddress
+	for _, ip := range external.ExternalIPs {
+		addresses = append(addresses, api.EndpointAddress{IP: ip})
+	}
+	srv := &api.Service{
+		ObjectMeta: meta,
+		Spec:       api.ServiceSpec{Ports: ports},
+	}
+	if len(ports) == 0 {
+		// Without ports the Service can only be headless, clients resolve
+		// the addresses of the endpoints.
+		srv.Spec.ClusterIP = api.ClusterIPNone
+	}
+	endpoints := &api.Endpoints{
+		ObjectMeta: meta,
+		Subsets: []api.EndpointSubset{
+			{Addresses: addresses, Ports: endpointPorts},
+		},
+	}
+	return []Object{
+		c.convertObject(shortName, "srv", service, srv),
+		c.convertObject(shortName, "ep", service, endpoints),
+	}
+}
+
+// createAliasService converts a Rancher DNS alias into a Service selecting
+// the pods of the services it links, on all their ports.
+func (c *conversion) createAliasService(name string, shortName string, service *config.ServiceConfig, dockerCompose *project.Project) Object {
+	linked := linkedServices(service)
+	if len(linked) == 0 {
+		failKey("links", "a DNS service needs to link a service")
+	}
+	selector := map[string]string{aliasLabelPrefix + shortName: "true"}
+	if len(linked) == 1 {
+		selector = map[string]string{"service": shortServiceName(linked[0])}
+	}
+	var ports []api.ServicePort
+	seen := map[int32]bool{}
+	for _, target := range linked {
+		targetService, ok := dockerCompose.ServiceConfigs.Get(target)
+		if !ok {
+			failKey("links", "unknown service %s", target)
+		}
+		for _, port := range c.configurePorts(target, targetService) {
+			if seen[port.ContainerPort] {
+				continue
+			}
+			seen[port.ContainerPort] = true
+			ports = append(ports, api.ServicePort{
+				Name:       fmt.Sprintf("port-%d", port.ContainerPort),
+				Port:       port.ContainerPort,
+				TargetPort: intstr.FromInt(int(port.ContainerPort)),
+			})
+		}
+	}
+	if len(ports) == 1 {
+		ports[0].Name = ""
+	}
+	srv := &api.Service{
+		ObjectMeta: api.ObjectMeta{
+			Name:      shortName,
+			Namespace: "${NAMESPACE}",
+			Labels:    configureLabels(shortName, service),
+		},
+		Spec: api.ServiceSpec{
+			Selector: selector,
+			Ports:    ports,
+		},
+	}
+	if len(ports) == 0 {
+		srv.Spec.ClusterIP = api.ClusterIPNone
+	}
+	return c.convertObject(shortName, "srv", service, srv)
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"strings"
+
+	"github.com/docker/libcompose/config"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/util/intstr"
+)
+
+// loadBalancerImage is the image of the Rancher load balancer services.
+const loadBalancerImage = "rancher/lb-service-haproxy"
+
+func isLoadBalancer(service *config.ServiceConfig) bool {
+	return strings.HasPrefix(service.Image, loadBalancerImage)
+}
+
+// portRule is a port rule of the lb_config of a Rancher load balancer.
+type portRule struct {
+	hostname   string
+	path       string
+	service    string
+	sourcePort int
+	targetPort int
+	protocol   string
+	// index is the position of the rule in port_rules.
+	index int
+}
+
+// targetService returns the short name of the service a port rule sends its
+// traffic to, without the stack it may be prefixed with.
+func (r portRule) targetService() string {
+	service := r.service[strings.LastIndex(r.service, "/")+1:]
+	if len(service) > 24 {
+		service = service[0:24]
+	}
+	return service
+}
+
+// parsePortRules reads the lb_config port rules of a load balancer. Rules
+// without a protocol are HTTP and rules without a target port use the
+// source port.
+func (c *conversion) parsePortRules(name string, rancherCompose *RancherCompose, lbConfig *RancherLBConfig) []portRule {
+	var rules []portRule
+	for i, item := range lbConfig.PortRules {
+		path := func(key string) []string {
+			return rancherCompose.servicePath(name, "lb_config", fmt.Sprintf("port_rules[%d]", i), key)
+		}
+		rule := portRule{
+			hostname:   item.Hostname,
+			path:       item.Path,
+			service:    item.Service,
+			protocol:   item.Protocol,
+			index:      i,
+			sourcePort: int(rancherCompose.intValue(item.SourcePort, 0, path("source_port")...)),
+			targetPort: int(rancherCompose.intValue(item.TargetPort, 0, path("target_port")...)),
+		}
+		if rule.protocol == "" {
+			rule.protocol = "http"
+		}
+		if rule.targetPort == 0 {
+			rule.targetPort = rule.sourcePort
+		}
+		if rule.service == "" {
+			c.warnf("load balancer %s: port rule on port %d has no target service, leaving it out", name, rule.sourcePort)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// secretName turns a Rancher certificate name into a Secret name.
+func secretName(cert string) string {
+	return strings.Trim(strings.ToLower(NonAlphanumRegexp.ReplaceAllString(cert, "-")), "-")
+}
+
+// loadBalancerServiceName returns the name of the Service of a load
+// balancer target, cut to 24 characters. A name the cut makes collide with
+// the Service of another target ends with a short hash of the full name.
+func loadBalancerServiceName(shortName string, target string, services map[string]*api.Service) string {
+	name := shortName + "-" + target
+	if len(name) <= 24 {
+		return name
+	}
+	// Cut names end with an alphanumeric character, as DNS labels do.
+	cut := strings.TrimRight(name[0:24], "-.")
+	for _, srv := range services {
+		if srv.Name == cut {
+			sum := sha1.Sum([]byte(name))
+			return fmt.Sprintf("%s-%x", strings.TrimRight(name[0:17], "-."), sum[:3])
+		}
+	}
+	return cut
+}
+
+// createLoadBalancer converts a Rancher load balancer into an Ingress for
+// its HTTP rules and a LoadBalancer Service per target of its TCP and UDP
+// rules. Its certificates become TLS secrets to fill in.
+func (c *conversion) createLoadBalancer(name string, shortName string, service *config.ServiceConfig, rancherCompose *RancherCompose) []Object {
+	lbConfig := &RancherLBConfig{}
+	if lb := rancherCompose.service(name); lb != nil && lb.LBConfig != nil {
+		lbConfig = lb.LBConfig
+	}
+	rules := c.parsePortRules(name, rancherCompose, lbConfig)
+	if len(rules) == 0 {
+		c.warnf("load balancer %s has no port rules, leaving it out", name)
+		return nil
+	}
+
+	var generated []Object
+	ingress := &extensions.Ingress{
+		ObjectMeta: api.ObjectMeta{
+			Name:      shortName,
+			Namespace: "${NAMESPACE}",
+			Labels:    configureLabels(shortName, service),
+		},
+	}
+	var tlsHosts []string
+	hosts := map[string]int{}
+	services := map[string]*api.Service{}
+	var serviceOrder []string
+	for _, rule := range rules {
+		switch rule.protocol {
+		case "http", "https", "sni":
+			backend := extensions.IngressBackend{
+				ServiceName: rule.targetService(),
+				ServicePort: intstr.FromInt(rule.targetPort),
+			}
+			index, ok := hosts[rule.hostname]
+			if !ok {
+				index = len(ingress.Spec.Rules)
+				hosts[rule.hostname] = index
+				ingress.Spec.Rules = append(ingress.Spec.Rules, extensions.IngressRule{
+					Host: rule.hostname,
+					IngressRuleValue: extensions.IngressRuleValue{
+						HTTP: &extensions.HTTPIngressRuleValue{},
+					},
+				})
+			}
+			// An Ingress path serves both HTTP and HTTPS.
+			http := ingress.Spec.Rules[index].HTTP
+			duplicate := false
+			for _, path := range http.Paths {
+				if path.Path == rule.path {
+					duplicate = true
+					if path.Backend != backend {
+						c.warnf("load balancer %s: %s%s already goes to %s, leaving out the rule to %s", name, rule.hostname, rule.path, path.Backend.ServiceName, backend.ServiceName)
+					}
+				}
+			}
+			if !duplicate {
+				http.Paths = append(http.Paths, extensions.HTTPIngressPath{Path: rule.path, Backend: backend})
+			}
+			if rule.protocol != "http" && rule.hostname != "" && !containsString(tlsHosts, rule.hostname) {
+				tlsHosts = append(tlsHosts, rule.hostname)
+			}
+		case "tcp", "tls", "udp":
+			if rule.protocol == "tls" {
+				c.warnf("load balancer %s: Services do not terminate TLS, port %d is forwarded as TCP", name, rule.sourcePort)
+			}
+			target := rule.targetService()
+			srv, ok := services[target]
+			if !ok {
+				srvName := loadBalancerServiceName(shortName, target, services)
+				srv = &api.Service{
+					ObjectMeta: api.ObjectMeta{
+						Name:      srvName,
+						Namespace: "${NAMESPACE}",
+						Labels:    configureLabels(shortName, service),
+					},
+					Spec: api.ServiceSpec{
+						Type:     api.ServiceTypeLoadBalancer,
+						Selector: map[string]string{"service": target},
+					},
+				}
+				services[target] = srv
+				serviceOrder = append(serviceOrder, target)
+			}
+			protocol := api.ProtocolTCP
+			if rule.protocol == "udp" {
+				protocol = api.ProtocolUDP
+			}
+			srv.Spec.Ports = append(srv.Spec.Ports, api.ServicePort{
+				Name:       fmt.Sprintf("%s-%d", rule.protocol, rule.sourcePort),
+				Protocol:   protocol,
+				Port:       int32(rule.sourcePort),
+				TargetPort: intstr.FromInt(rule.targetPort),
+			})
+		default:
+			rancherCompose.fatalf(rancherCompose.servicePath(name, "lb_config", fmt.Sprintf("port_rules[%d]", rule.index), "protocol"), "unknown protocol %s", rule.protocol)
+		}
+	}
+
+	var certs []string
+	if cert := lbConfig.DefaultCert; cert != "" {
+		certs = append(certs, cert)
+		ingress.Spec.TLS = append(ingress.Spec.TLS, extensions.IngressTLS{Hosts: tlsHosts, SecretName: secretName(cert)})
+	} else if len(tlsHosts) > 0 {
+		c.warnf("load balancer %s has HTTPS rules but no default_cert", name)
+	}
+	for _, cert := range lbConfig.Certs {
+		if containsString(certs, cert) {
+			continue
+		}
+		certs = append(certs, cert)
+		ingress.Spec.TLS = append(ingress.Spec.TLS, extensions.IngressTLS{SecretName: secretName(cert)})
+	}
+
+	if len(ingress.Spec.Rules) > 0 {
+		generated = append(generated, c.convertObject(shortName, "ing", service, ingress))
+	}
+	for _, target := range serviceOrder {
+		srv := services[target]
+		generated = append(generated, c.convertObject(srv.Name, "srv", service, srv))
+	}
+	for _, cert := range certs {
+		c.warnf("load balancer %s: fill in the certificate and key of secret %s", name, secretName(cert))
+		secret := &api.Secret{
+			ObjectMeta: api.ObjectMeta{
+				Name:      secretName(cert),
+				Namespace: "${NAMESPACE}",
+			},
+			Type: api.SecretTypeTLS,
+			Data: map[string][]byte{
+				api.TLSCertKey:       {},
+				api.TLSPrivateKeyKey: {},
+			},
+		}
+		generated = append(generated, c.convertObject(secretName(cert), "secret", service, secret))
+	}
+	return generated
+}
@@ -11,25 +11,19 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-package main
+package converter
 
 import (
-	"log"
 	"strconv"
 	"strings"
 
 	"github.com/docker/libcompose/config"
 
 	"k8s.io/kubernetes/pkg/api"
-	"k8s.io/kubernetes/pkg/api/unversioned"
 )
 
-func createReplicationController(name string, shortName string, service *config.ServiceConfig, rancherCompose map[interface{}]interface{}) *api.ReplicationController {
-	rc := &api.ReplicationController{
-		TypeMeta: unversioned.TypeMeta{
-			Kind:       "ReplicationController",
-			APIVersion: "v1",
-		},
+func createReplicationController(name string, shortName string, service *config.ServiceConfig, template *api.PodTemplateSpec, rancherCompose *RancherCompose) *api.ReplicationController {
+	return &api.ReplicationController{
 		ObjectMeta: api.ObjectMeta{
 			Name:      shortName,
 			Namespace: "${NAMESPACE}",
@@ -38,31 +32,36 @@ func createReplicationController(name string, shortName string, service *config.
 		Spec: api.ReplicationControllerSpec{
 			Replicas: configureScale(name, rancherCompose),
 			Selector: map[string]string{"service": shortName},
-			Template: &api.PodTemplateSpec{
-				ObjectMeta: api.ObjectMeta{
-					Labels: map[string]string{"service": shortName},
-				},
-				Spec: api.PodSpec{
-					Containers: []api.Container{
-						{
-							Name:           shortName,
-							Image:          service.Image,
-							Command:        service.Command,
-							Ports:          configurePorts(name, service),
-							Env:            configureVariables(service),
-							ReadinessProbe: configureHealthCheck(name, rancherCompose),
-						},
-					},
-					RestartPolicy: configureRestartPolicy(name, service),
+			Template: template,
+		},
+	}
+}
+
+func (c *conversion) createPodTemplate(name string, shortName string, service *config.ServiceConfig, rancherCompose *RancherCompose) *api.PodTemplateSpec {
+	template := &api.PodTemplateSpec{
+		ObjectMeta: api.ObjectMeta{
+			Labels: configureLabels(shortName, service),
+		},
+		Spec: api.PodSpec{
+			Containers: []api.Container{
+				{
+					Name:    shortName,
+					Image:   service.Image,
+					Command: service.Command,
+					Ports:   c.configurePorts(name, service),
+					Env:     configureVariables(service),
 				},
 			},
+			RestartPolicy: c.configureRestartPolicy(name, service),
 		},
 	}
-	rc.Spec.Template.Spec.Containers[0].VolumeMounts, rc.Spec.Template.Spec.Volumes = configureVolumes(service)
-	return rc
+	template.Spec.Containers[0].LivenessProbe, template.Spec.Containers[0].ReadinessProbe = c.configureProbes(name, rancherCompose)
+	template.Spec.Containers[0].VolumeMounts, template.Spec.Volumes = c.configureVolumes(name, service)
+	c.configureScheduling(name, service, template)
+	return template
 }
 
-func configurePorts(name string, service *config.ServiceConfig) []api.ContainerPort {
+func (c *conversion) configurePorts(name string, service *config.ServiceConfig) []api.ContainerPort {
 	var ports []api.ContainerPort
 	for _, port := range service.Ports {
 		// Check if we have to deal with a mapped port
@@ -74,7 +73,8 @@ func configurePorts(name string, service *config.ServiceConfig) []api.ContainerP
 		}
 		portNumber, err := strconv.ParseInt(port, 10, 32)
 		if err != nil {
-			log.Fatalf("Invalid container port %s for service %s", port, name)
+			c.errorf(name, "ports", "invalid container port %s", port)
+			continue
 		}
 		ports = append(ports, api.ContainerPort{ContainerPort: int32(portNumber)})
 	}
@@ -86,7 +86,7 @@ func configureVariables(service *config.ServiceConfig) []api.EnvVar {
 	var envs []api.EnvVar
 	for _, env := range service.Environment {
 		if strings.Contains(env, "=") {
-			parts := strings.Split(env, "=")
+			parts := strings.SplitN(env, "=", 2)
 			ename := parts[0]
 			evalue := parts[1]
 			envs = append(envs, api.EnvVar{Name: ename, Value: evalue})
@@ -99,19 +99,23 @@ func configureLabels(shortName string, service *config.ServiceConfig) map[string
 	labels := make(map[string]string, len(service.Labels)+1)
 	labels["service"] = shortName
 	for index, label := range service.Labels {
+		if strings.HasPrefix(index, controllerLabelPrefix) || strings.HasPrefix(index, schedulerLabelPrefix) || index == sidekicksLabel || index == startOnceLabel {
+			continue
+		}
 		labels[index] = label
 	}
 	return labels
 }
 
-func configureVolumes(service *config.ServiceConfig) ([]api.VolumeMount, []api.Volume) {
+func (c *conversion) configureVolumes(name string, service *config.ServiceConfig) ([]api.VolumeMount, []api.Volume) {
 	var volumemounts []api.VolumeMount
 	var volumes []api.Volume
 	if service.Volumes != nil {
 		for _, volumestr := range service.Volumes.Volumes {
 			parts := strings.Split(volumestr.String(), ":")
 			if len(parts) < 2 {
-				log.Fatalf("Volumes without host path are not supported: %s", parts)
+				c.errorf(name, "volumes", "volumes without host path are not supported: %s", volumestr)
+				continue
 			}
 			partHostDir := parts[0]
 			partContainerDir := parts[1]
@@ -144,7 +148,7 @@ func configureVolumes(service *config.ServiceConfig) ([]api.VolumeMount, []api.V
 	return volumemounts, volumes
 }
 
-func configureRestartPolicy(name string, service *config.ServiceConfig) api.RestartPolicy {
+func (c *conversion) configureRestartPolicy(name string, service *config.ServiceConfig) api.RestartPolicy {
 	restartPolicy := api.RestartPolicyAlways
 	switch service.Restart {
 	case "", "always":
@@ -154,7 +158,7 @@ func configureRestartPolicy(name string, service *config.ServiceConfig) api.Rest
 	case "on-failure":
 		restartPolicy = api.RestartPolicyOnFailure
 	default:
-		log.Fatalf("Unknown restart policy %s for service %s", service.Restart, name)
+		c.errorf(name, "restart", "unknown restart policy %s", service.Restart)
 	}
 	return restartPolicy
 }
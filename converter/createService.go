@@ -11,29 +11,38 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-package main
+package converter
 
 import (
+	"fmt"
+
 	"github.com/docker/libcompose/config"
 
 	"k8s.io/kubernetes/pkg/api"
-	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/util/intstr"
 )
 
-func createService(shortName string, service *config.ServiceConfig, rc *api.ReplicationController) *api.Service {
-	ports := make([]api.ServicePort, len(rc.Spec.Template.Spec.Containers[0].Ports))
-	for i, port := range rc.Spec.Template.Spec.Containers[0].Ports {
-		ports[i].Port = port.ContainerPort
+func createService(shortName string, service *config.ServiceConfig, template *api.PodTemplateSpec) *api.Service {
+	// Sidekicks share the Service of their primary container.
+	var ports []api.ServicePort
+	for _, container := range template.Spec.Containers {
+		for _, port := range container.Ports {
+			ports = append(ports, api.ServicePort{
+				Name:       fmt.Sprintf("%s-%d", container.Name, port.ContainerPort),
+				Port:       port.ContainerPort,
+				TargetPort: intstr.FromInt(int(port.ContainerPort)),
+			})
+		}
+	}
+	// Only Services with several ports need to name them.
+	if len(ports) == 1 {
+		ports[0].Name = ""
 	}
 
 	srv := &api.Service{
-		TypeMeta: unversioned.TypeMeta{
-			Kind:       "Service",
-			APIVersion: "v1",
-		},
 		ObjectMeta: api.ObjectMeta{
 			Name:      shortName,
-			Namespace: "default",
+			Namespace: "${NAMESPACE}",
 		},
 		Spec: api.ServiceSpec{
 			Selector: map[string]string{"service": shortName},
@@ -0,0 +1,118 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Error is a problem with a key of a compose service. Location is the
+// file and line of the key, when known.
+type Error struct {
+	Service  string
+	Key      string
+	Message  string
+	Location string
+}
+
+func (e *Error) Error() string {
+	var parts []string
+	if e.Location != "" {
+		parts = append(parts, e.Location)
+	}
+	if e.Service != "" {
+		parts = append(parts, "service "+e.Service)
+	}
+	if e.Key != "" {
+		parts = append(parts, e.Key)
+	}
+	return strings.Join(append(parts, e.Message), ": ")
+}
+
+// Errors lists the problems of every service of a conversion.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// conversionError carries the error stopping a conversion, or the
+// conversion of a service, to the function recovering it.
+type conversionError struct {
+	err error
+}
+
+// fail stops the conversion with an error.
+func fail(format string, args ...interface{}) {
+	panic(conversionError{fmt.Errorf(format, args...)})
+}
+
+// failKey stops the conversion of the current service with an error of
+// one of its keys.
+func failKey(key string, format string, args ...interface{}) {
+	panic(conversionError{&Error{Key: key, Message: fmt.Sprintf(format, args...)}})
+}
+
+// recoverError turns the panic of fail into the error returned by the
+// function deferring it.
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		e, ok := r.(conversionError)
+		if !ok {
+			panic(r)
+		}
+		*err = e.err
+	}
+}
+
+// errorf records an error of a key of a service. The conversion goes on to
+// report the other errors at once.
+func (c *conversion) errorf(service string, key string, format string, args ...interface{}) {
+	c.addError(&Error{Service: service, Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *conversion) addError(err *Error) {
+	for _, known := range c.errors {
+		if *known == *err {
+			return
+		}
+	}
+	c.errors = append(c.errors, err)
+	c.failed[err.Service] = true
+}
+
+// recoverService records the error stopping the conversion of a service.
+func (c *conversion) recoverService(name string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	e, ok := r.(conversionError)
+	if !ok {
+		panic(r)
+	}
+	err, ok := e.err.(*Error)
+	if !ok {
+		err = &Error{Message: e.err.Error()}
+	}
+	if err.Service == "" {
+		err.Service = name
+	}
+	c.addError(err)
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// extensionKey is the compose extension holding Kubernetes fragments, at
+// the top level for every service or in a service for its objects.
+const extensionKey = "x-kubernetes"
+
+// kubernetesExtension holds the fragments of an x-kubernetes section, each
+// a strategic merge patch when it is a map or a JSON patch when it is a
+// list.
+type kubernetesExtension struct {
+	// Workload patches the controller, Service the Service and Pod the pod
+	// template of the controller.
+	Workload interface{} `json:"workload,omitempty"`
+	Service  interface{} `json:"service,omitempty"`
+	Pod      interface{} `json:"pod,omitempty"`
+}
+
+// projectExtensions holds the x-kubernetes sections of a project. The sections of
+// later files override the whole section of the earlier ones.
+type projectExtensions struct {
+	project  *kubernetesExtension
+	services map[string]*kubernetesExtension
+	// topLevel lists the extension keys of version 1 files, read by the
+	// parser as services.
+	topLevel []string
+}
+
+// parseExtensions reads the x-kubernetes sections of the compose files.
+func (c *Converter) parseExtensions(composeFiles ...string) projectExtensions {
+	e := projectExtensions{services: map[string]*kubernetesExtension{}}
+	for _, composeFile := range composeFiles {
+		file, err := ioutil.ReadFile(composeFile)
+		if err != nil {
+			fail("Failed to read %s: %v", composeFile, err)
+		}
+		var top map[string]interface{}
+		if err := yaml.Unmarshal(file, &top); err != nil {
+			fail("Failed to parse %s: %v", composeFile, err)
+		}
+		if c.options.Answers != nil {
+			substituteAnswers(top, c.options.Answers)
+		}
+		services, _ := top["services"].(map[string]interface{})
+		if top["version"] == nil {
+			// Version 1 files list the services at the top level.
+			services = top
+			for key := range top {
+				if strings.HasPrefix(key, "x-") {
+					e.topLevel = append(e.topLevel, key)
+				}
+			}
+		}
+		if section, ok := top[extensionKey]; ok {
+			e.project = parseExtension(composeFile, extensionKey, section)
+		}
+		for name, service := range services {
+			fields, _ := service.(map[string]interface{})
+			if section, ok := fields[extensionKey]; ok && !strings.HasPrefix(name, "x-") {
+				e.services[name] = parseExtension(composeFile, name+"."+extensionKey, section)
+			}
+		}
+	}
+	return e
+}
+
+func parseExtension(composeFile string, path string, section interface{}) *kubernetesExtension {
+	data, err := yaml.Marshal(section)
+	if err != nil {
+		fail("%s: %s: %v", composeFile, path, err)
+	}
+	var extension kubernetesExtension
+	if err := yaml.Unmarshal(data, &extension); err != nil {
+		fail("%s: %s: %v", composeFile, path, err)
+	}
+	return &extension
+}
+
+// applyExtensions patches the objects of a service with the top level
+// x-kubernetes section, then with the one of the service.
+func (c *conversion) applyExtensions(name string, objects []Object) {
+	for _, extension := range []*kubernetesExtension{c.extensions.project, c.extensions.services[name]} {
+		if extension == nil {
+			continue
+		}
+		for i := range objects {
+			object := &objects[i]
+			if object.Versioned["kind"] == "Service" {
+				object.Versioned = applyFragment("service", object.Versioned, extension.Service)
+				continue
+			}
+			template := PodTemplateOf(object.Versioned)
+			if template == nil {
+				continue
+			}
+			object.Versioned = applyFragment("workload", object.Versioned, extension.Workload)
+			if template = PodTemplateOf(object.Versioned); template != nil {
+				// The template is patched in place, its entries are
+				// replaced by the ones of the patched template.
+				patched := map[string]interface{}{}
+				for key, value := range applyFragment("pod", template, extension.Pod) {
+					patched[key] = value
+				}
+				for key := range template {
+					delete(template, key)
+				}
+				for key, value := range patched {
+					template[key] = value
+				}
+			}
+		}
+	}
+}
+
+// applyFragment applies a fragment of an x-kubernetes section to an object,
+// stopping the conversion of the service when it fails.
+func applyFragment(key string, object map[string]interface{}, fragment interface{}) map[string]interface{} {
+	switch fragment := fragment.(type) {
+	case nil:
+		return object
+	case map[string]interface{}:
+		return StrategicMerge(object, fragment)
+	case []interface{}:
+		patched, err := JSONPatch(object, fragment)
+		if err != nil {
+			failKey(extensionKey+"."+key, "%v", err)
+		}
+		return patched
+	}
+	failKey(extensionKey+"."+key, "expected a map or a list of JSON patch operations, got %v", fragment)
+	return nil
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// indexAnnotation numbers the items of a ResourceList, the way kustomize
+// does, to tell the objects returned by a function apart.
+const indexAnnotation = "config.kubernetes.io/index"
+
+// Function is an external command transforming the objects the way KRM
+// functions do: it reads a ResourceList of them on its standard input and
+// writes the transformed list on its standard output.
+type Function struct {
+	// Name names the function in the messages, Exec when empty.
+	Name string `json:"name,omitempty"`
+	// Exec is the command, relative paths are relative to the functions
+	// file.
+	Exec string   `json:"exec"`
+	Args []string `json:"args,omitempty"`
+	// Config is the functionConfig of the ResourceList.
+	Config map[string]interface{} `json:"config,omitempty"`
+	// OnFailure is fail, the default, to stop the conversion when the
+	// function fails, or warn to go on with the objects unchanged.
+	OnFailure string `json:"onFailure,omitempty"`
+}
+
+// resourceList is the input and output of a function.
+type resourceList struct {
+	APIVersion     string                   `json:"apiVersion"`
+	Kind           string                   `json:"kind"`
+	Items          []map[string]interface{} `json:"items"`
+	FunctionConfig map[string]interface{}   `json:"functionConfig,omitempty"`
+	Results        []functionResult         `json:"results,omitempty"`
+}
+
+// functionResult is a message of a function about the objects.
+type functionResult struct {
+	Message  string `json:"message"`
+	Severity string `json:"severity,omitempty"`
+}
+
+// LoadFunctions reads the functions file: the functions listed under
+// functions, run in order.
+func LoadFunctions(path string) ([]Transformer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the functions file %s: %v", path, err)
+	}
+	var file struct {
+		Functions []Function `json:"functions"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("Failed to parse the functions file %s: %v", path, err)
+	}
+	var transformers []Transformer
+	for i, function := range file.Functions {
+		if function.Exec == "" {
+			return nil, fmt.Errorf("%s: function %d has no exec", path, i+1)
+		}
+		switch function.OnFailure {
+		case "":
+			function.OnFailure = "fail"
+		case "fail", "warn":
+		default:
+			return nil, fmt.Errorf("%s: unknown onFailure %s of function %s, expected fail or warn", path, function.OnFailure, function.Exec)
+		}
+		if strings.Contains(function.Exec, "/") && !filepath.IsAbs(function.Exec) {
+			function.Exec = filepath.Join(filepath.Dir(path), function.Exec)
+		}
+		if function.Name == "" {
+			function.Name = function.Exec
+		}
+		transformers = append(transformers, function)
+	}
+	return transformers, nil
+}
+
+// Transform runs the function over the objects.
+func (f Function) Transform(objects []Object) ([]Object, []string, error) {
+	transformed, warnings, err := f.run(objects)
+	if err != nil && f.OnFailure == "warn" {
+		return objects, append(warnings, fmt.Sprintf("function %s failed, leaving the objects unchanged: %v", f.Name, err)), nil
+	}
+	if err != nil {
+		return nil, warnings, fmt.Errorf("function %s: %v", f.Name, err)
+	}
+	return transformed, warnings, nil
+}
+
+func (f Function) run(objects []Object) ([]Object, []string, error) {
+	input := resourceList{
+		APIVersion:     "config.kubernetes.io/v1",
+		Kind:           "ResourceList",
+		FunctionConfig: f.Config,
+	}
+	for i, object := range objects {
+		item := copyObject(object.Versioned)
+		metadata, _ := item["metadata"].(map[string]interface{})
+		if metadata == nil {
+			metadata = map[string]interface{}{}
+			item["metadata"] = metadata
+		}
+		annotations, _ := metadata["annotations"].(map[string]interface{})
+		if annotations == nil {
+			annotations = map[string]interface{}{}
+			metadata["annotations"] = annotations
+		}
+		annotations[indexAnnotation] = strconv.Itoa(i)
+		input.Items = append(input.Items, item)
+	}
+	data, err := yaml.Marshal(input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(f.Exec, f.Args...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			err = fmt.Errorf("%v: %s", err, message)
+		}
+		return nil, nil, err
+	}
+	var output resourceList
+	if err := yaml.Unmarshal(stdout.Bytes(), &output); err != nil {
+		return nil, nil, fmt.Errorf("invalid ResourceList: %v", err)
+	}
+	if output.Kind != "ResourceList" {
+		return nil, nil, fmt.Errorf("expected a ResourceList, got %q", output.Kind)
+	}
+
+	var warnings []string
+	var failures []string
+	for _, result := range output.Results {
+		switch result.Severity {
+		case "error":
+			failures = append(failures, result.Message)
+		case "warning":
+			warnings = append(warnings, fmt.Sprintf("function %s: %s", f.Name, result.Message))
+		}
+	}
+	if len(failures) > 0 {
+		return nil, warnings, fmt.Errorf("%s", strings.Join(failures, ", "))
+	}
+
+	var transformed []Object
+	for _, item := range output.Items {
+		object := Object{Versioned: item}
+		if index, ok := takeIndex(item); ok && index >= 0 && index < len(objects) {
+			// The object keeps its file and service.
+			object.Name, object.Suffix = objects[index].Name, objects[index].Suffix
+			object.Service = objects[index].Service
+		} else {
+			name, _ := GetField(item, "metadata", "name").(string)
+			kind, _ := item["kind"].(string)
+			object.Name, object.Suffix = name, strings.ToLower(kind)
+		}
+		transformed = append(transformed, object)
+	}
+	return transformed, warnings, nil
+}
+
+// takeIndex removes the index annotation from an item and returns it.
+func takeIndex(item map[string]interface{}) (int, bool) {
+	metadata, _ := item["metadata"].(map[string]interface{})
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	value, ok := annotations[indexAnnotation].(string)
+	if !ok {
+		return 0, false
+	}
+	delete(annotations, indexAnnotation)
+	if len(annotations) == 0 {
+		delete(metadata, "annotations")
+	}
+	index, err := strconv.Atoi(value)
+	return index, err == nil
+}
+
+// copyObject returns a deep copy of a versioned object.
+func copyObject(object map[string]interface{}) map[string]interface{} {
+	data, _ := yaml.Marshal(object)
+	var copied map[string]interface{}
+	yaml.Unmarshal(data, &copied)
+	return copied
+}
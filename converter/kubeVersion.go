@@ -0,0 +1,314 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// apiVersionRange describes the Kubernetes releases serving a kind under a
+// given group version. until is exclusive, zero means it is still served.
+type apiVersionRange struct {
+	since      int
+	until      int
+	apiVersion string
+	kind       string
+}
+
+// kindVersions lists, oldest first, where each kind generated by compose2kube
+// is served. Alpha group versions are left out since they are disabled by
+// default on a cluster.
+var kindVersions = map[string][]apiVersionRange{
+	"ReplicationController": {
+		{since: 0, apiVersion: "v1", kind: "ReplicationController"},
+	},
+	"Service": {
+		{since: 0, apiVersion: "v1", kind: "Service"},
+	},
+	"Endpoints": {
+		{since: 0, apiVersion: "v1", kind: "Endpoints"},
+	},
+	"Secret": {
+		{since: 0, apiVersion: "v1", kind: "Secret"},
+	},
+	"Deployment": {
+		{since: 2, until: 16, apiVersion: "extensions/v1beta1", kind: "Deployment"},
+		{since: 6, until: 16, apiVersion: "apps/v1beta1", kind: "Deployment"},
+		{since: 9, apiVersion: "apps/v1", kind: "Deployment"},
+	},
+	"DaemonSet": {
+		{since: 2, until: 16, apiVersion: "extensions/v1beta1", kind: "DaemonSet"},
+		{since: 9, apiVersion: "apps/v1", kind: "DaemonSet"},
+	},
+	"StatefulSet": {
+		{since: 5, until: 16, apiVersion: "apps/v1beta1", kind: "StatefulSet"},
+		{since: 9, apiVersion: "apps/v1", kind: "StatefulSet"},
+	},
+	"CronJob": {
+		{since: 8, until: 25, apiVersion: "batch/v1beta1", kind: "CronJob"},
+		{since: 21, apiVersion: "batch/v1", kind: "CronJob"},
+	},
+	"Ingress": {
+		{since: 1, until: 22, apiVersion: "extensions/v1beta1", kind: "Ingress"},
+		{since: 14, until: 22, apiVersion: "networking.k8s.io/v1beta1", kind: "Ingress"},
+		{since: 19, apiVersion: "networking.k8s.io/v1", kind: "Ingress"},
+	},
+}
+
+// vendoredKinds maps the kinds of the vendored API types to the kind they
+// were later renamed to.
+var vendoredKinds = map[string]string{
+	"PetSet":       "StatefulSet",
+	"ScheduledJob": "CronJob",
+}
+
+// fieldSupport describes a field that is only understood by some releases.
+// Path elements are map keys, "*" walks every item of a list.
+type fieldSupport struct {
+	kinds []string
+	path  string
+	since int
+	until int
+}
+
+var podTemplateKinds = []string{"ReplicationController", "Deployment", "DaemonSet", "StatefulSet"}
+
+var fieldSupports = []fieldSupport{
+	{kinds: podTemplateKinds, path: "spec.template.spec.initContainers", since: 6},
+	{kinds: podTemplateKinds, path: "spec.template.spec.containers.*.startupProbe", since: 16},
+	{kinds: []string{"CronJob"}, path: "spec.jobTemplate.spec.template.spec.initContainers", since: 6},
+	{kinds: []string{"DaemonSet"}, path: "spec.updateStrategy", since: 6},
+	{kinds: []string{"StatefulSet"}, path: "spec.updateStrategy", since: 7},
+	{kinds: []string{"Deployment"}, path: "spec.rollbackTo", until: 9},
+}
+
+// parseKubeVersion returns the minor release of a Kubernetes version such as
+// 1.9, v1.9 or 1.9.3.
+func parseKubeVersion(version string) (int, error) {
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(parts) < 2 || parts[0] != "1" {
+		return 0, fmt.Errorf("expected a 1.x release")
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil || minor < 0 {
+		return 0, fmt.Errorf("invalid minor release %q", parts[1])
+	}
+	return minor, nil
+}
+
+func (r apiVersionRange) served(minor int) bool {
+	return minor >= r.since && (r.until == 0 || minor < r.until)
+}
+
+// kindAvailable reports if kind is served by the target release.
+func (c *conversion) kindAvailable(kind string) bool {
+	_, ok := c.apiVersionFor(kind)
+	return ok
+}
+
+// apiVersionFor returns the newest group version serving kind on the target
+// release.
+func (c *conversion) apiVersionFor(kind string) (apiVersionRange, bool) {
+	ranges := kindVersions[kind]
+	for i := len(ranges) - 1; i >= 0; i-- {
+		if ranges[i].served(c.targetMinor) {
+			return ranges[i], true
+		}
+	}
+	return apiVersionRange{}, false
+}
+
+// adjustForKubeVersion rewrites the apiVersion and kind of a versioned
+// object for the target release and drops the fields that release does not
+// understand.
+func (c *conversion) adjustForKubeVersion(object map[string]interface{}) {
+	kind, _ := object["kind"].(string)
+	if renamed, ok := vendoredKinds[kind]; ok {
+		kind = renamed
+	}
+	if r, ok := c.apiVersionFor(kind); ok {
+		object["apiVersion"] = r.apiVersion
+		object["kind"] = r.kind
+	} else {
+		c.warnf("%s is not available on Kubernetes 1.%d", kind, c.targetMinor)
+	}
+
+	name, _ := GetField(object, "metadata", "name").(string)
+	if object["apiVersion"] == "networking.k8s.io/v1" && kind == "Ingress" {
+		adjustIngressBackends(object)
+	}
+	c.adjustPodAnnotations(kind, name, PodTemplateOf(object))
+	for _, field := range fieldSupports {
+		if !containsString(field.kinds, kind) {
+			continue
+		}
+		r := apiVersionRange{since: field.since, until: field.until}
+		if r.served(c.targetMinor) {
+			continue
+		}
+		if removeField(object, strings.Split(field.path, ".")) {
+			c.warnf("%s %s: %s is not supported on Kubernetes 1.%d, leaving it out", kind, name, field.path, c.targetMinor)
+		}
+	}
+}
+
+// podAnnotationField describes a pod spec field that releases before 1.6
+// read from an annotation. The vendored types encode it in the alpha
+// annotation, some releases also read a beta one.
+type podAnnotationField struct {
+	field           string
+	alphaAnnotation string
+	alphaSince      int
+	betaAnnotation  string
+	betaSince       int
+	fieldSince      int
+}
+
+var podAnnotationFields = []podAnnotationField{
+	{
+		field:           "initContainers",
+		alphaAnnotation: "pod.alpha.kubernetes.io/init-containers",
+		alphaSince:      3,
+		betaAnnotation:  "pod.beta.kubernetes.io/init-containers",
+		betaSince:       4,
+		fieldSince:      6,
+	},
+	{
+		field:           "affinity",
+		alphaAnnotation: "scheduler.alpha.kubernetes.io/affinity",
+		alphaSince:      2,
+		fieldSince:      6,
+	},
+}
+
+// adjustPodAnnotations moves the pod spec fields the vendored types encode
+// in annotations to where the target release reads them.
+func (c *conversion) adjustPodAnnotations(kind string, name string, template map[string]interface{}) {
+	annotations, _ := GetField(template, "metadata", "annotations").(map[string]interface{})
+	for _, f := range podAnnotationFields {
+		value, ok := annotations[f.alphaAnnotation].(string)
+		if !ok {
+			continue
+		}
+		switch {
+		case c.targetMinor >= f.fieldSince:
+			var field interface{}
+			if err := json.Unmarshal([]byte(value), &field); err != nil {
+				fail("Failed to read the %s of %s %s: %v", f.field, kind, name, err)
+			}
+			pruneNulls(field)
+			delete(annotations, f.alphaAnnotation)
+			if spec, ok := template["spec"].(map[string]interface{}); ok {
+				spec[f.field] = field
+			}
+		case f.betaAnnotation != "" && c.targetMinor >= f.betaSince:
+			delete(annotations, f.alphaAnnotation)
+			annotations[f.betaAnnotation] = value
+		case c.targetMinor < f.alphaSince:
+			c.warnf("%s %s: %s is not supported on Kubernetes 1.%d, leaving it out", kind, name, f.field, c.targetMinor)
+			delete(annotations, f.alphaAnnotation)
+		}
+	}
+	if annotations != nil && len(annotations) == 0 {
+		delete(template["metadata"].(map[string]interface{}), "annotations")
+	}
+}
+
+// adjustIngressBackends rewrites the backends of an Ingress to the
+// networking.k8s.io/v1 form, where paths also need a type.
+func adjustIngressBackends(object map[string]interface{}) {
+	spec, _ := object["spec"].(map[string]interface{})
+	if backend, ok := spec["backend"].(map[string]interface{}); ok {
+		spec["defaultBackend"] = ingressBackend(backend)
+		delete(spec, "backend")
+	}
+	rules, _ := spec["rules"].([]interface{})
+	for _, rule := range rules {
+		paths, _ := GetField(rule, "http", "paths").([]interface{})
+		for _, item := range paths {
+			path := item.(map[string]interface{})
+			if backend, ok := path["backend"].(map[string]interface{}); ok {
+				path["backend"] = ingressBackend(backend)
+			}
+			if p, _ := path["path"].(string); p == "" {
+				path["path"] = "/"
+			}
+			path["pathType"] = "Prefix"
+		}
+	}
+}
+
+func ingressBackend(backend map[string]interface{}) map[string]interface{} {
+	port := map[string]interface{}{"number": backend["servicePort"]}
+	if name, ok := backend["servicePort"].(string); ok {
+		port = map[string]interface{}{"name": name}
+	}
+	return map[string]interface{}{
+		"service": map[string]interface{}{
+			"name": backend["serviceName"],
+			"port": port,
+		},
+	}
+}
+
+// GetField walks object along path and returns the value found, or nil.
+func GetField(object interface{}, path ...string) interface{} {
+	for _, key := range path {
+		m, ok := object.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		object = m[key]
+	}
+	return object
+}
+
+// removeField deletes path from object and reports if anything was removed.
+func removeField(object interface{}, path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	if path[0] == "*" {
+		items, _ := object.([]interface{})
+		removed := false
+		for _, item := range items {
+			if removeField(item, path[1:]) {
+				removed = true
+			}
+		}
+		return removed
+	}
+	m, ok := object.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if len(path) == 1 {
+		_, found := m[path[0]]
+		delete(m, path[0])
+		return found
+	}
+	return removeField(m[path[0]], path[1:])
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
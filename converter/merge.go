@@ -0,0 +1,168 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Conflict is a field changed both by hand and by the conversion. Edited is
+// the value kept, Generated the one of the new conversion; missing values
+// are nil.
+type Conflict struct {
+	Path      string
+	Edited    interface{}
+	Generated interface{}
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s: kept the edited %s instead of the generated %s", c.Path, conflictValue(c.Edited), conflictValue(c.Generated))
+}
+
+func conflictValue(value interface{}) string {
+	if value == nil {
+		return "nothing"
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// absent stands for a missing field in a three-way merge.
+var absent = &struct{}{}
+
+// ThreeWayMerge merges the changes made by hand to a generated object,
+// edited from previous, with the ones of the new conversion, generated.
+// Fields changed on one side take the value of that side. Fields changed
+// differently on both sides keep the edited value and are returned as
+// conflicts. Maps are merged by key, and the lists of mergeKeys by the key
+// of their items; other lists are merged as a whole.
+func ThreeWayMerge(previous, edited, generated map[string]interface{}) (map[string]interface{}, []Conflict) {
+	var conflicts []Conflict
+	merged := threeWayMerge("", "", previous, edited, generated, &conflicts)
+	object, _ := merged.(map[string]interface{})
+	return object, conflicts
+}
+
+func threeWayMerge(path string, field string, previous, edited, generated interface{}, conflicts *[]Conflict) interface{} {
+	switch {
+	case reflect.DeepEqual(edited, generated), reflect.DeepEqual(previous, generated):
+		return edited
+	case reflect.DeepEqual(previous, edited):
+		return generated
+	}
+	if editedMap, ok := edited.(map[string]interface{}); ok {
+		if generatedMap, ok := generated.(map[string]interface{}); ok {
+			previousMap, _ := previous.(map[string]interface{})
+			merged := map[string]interface{}{}
+			for _, key := range unionKeys(previousMap, editedMap, generatedMap) {
+				value := threeWayMerge(joinPath(path, key), key, fieldOf(previousMap, key), fieldOf(editedMap, key), fieldOf(generatedMap, key), conflicts)
+				if value != absent {
+					merged[key] = value
+				}
+			}
+			return merged
+		}
+	}
+	if editedList, ok := edited.([]interface{}); ok {
+		if generatedList, ok := generated.([]interface{}); ok {
+			previousList, _ := previous.([]interface{})
+			if key := ListMergeKey(field, previousList, editedList, generatedList); key != "" {
+				return mergeLists(path, key, previousList, editedList, generatedList, conflicts)
+			}
+		}
+	}
+	*conflicts = append(*conflicts, Conflict{Path: path, Edited: presentValue(edited), Generated: presentValue(generated)})
+	return edited
+}
+
+// mergeLists merges lists item by item, the items matched by key. The
+// merged list follows the order of the generated one, the items added by
+// hand come last.
+func mergeLists(path string, key string, previous, edited, generated []interface{}, conflicts *[]Conflict) []interface{} {
+	index := func(items []interface{}) (map[string]interface{}, []string) {
+		byKey := map[string]interface{}{}
+		var order []string
+		for _, item := range items {
+			k := fmt.Sprint(item.(map[string]interface{})[key])
+			byKey[k] = item
+			order = append(order, k)
+		}
+		return byKey, order
+	}
+	previousItems, _ := index(previous)
+	editedItems, editedOrder := index(edited)
+	generatedItems, generatedOrder := index(generated)
+	merged := []interface{}{}
+	seen := map[string]bool{}
+	for _, k := range append(generatedOrder, editedOrder...) {
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		value := threeWayMerge(fmt.Sprintf("%s[%s=%s]", path, key, k), "", itemOf(previousItems, k), itemOf(editedItems, k), itemOf(generatedItems, k), conflicts)
+		if value != absent {
+			merged = append(merged, value)
+		}
+	}
+	return merged
+}
+
+func unionKeys(maps ...map[string]interface{}) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range maps {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func fieldOf(m map[string]interface{}, key string) interface{} {
+	if value, ok := m[key]; ok {
+		return value
+	}
+	return absent
+}
+
+func itemOf(items map[string]interface{}, key string) interface{} {
+	if item, ok := items[key]; ok {
+		return item
+	}
+	return absent
+}
+
+func presentValue(value interface{}) interface{} {
+	if value == absent {
+		return nil
+	}
+	return value
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
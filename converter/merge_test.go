@@ -0,0 +1,117 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestThreeWayMerge(t *testing.T) {
+	tests := []struct {
+		name      string
+		previous  string
+		edited    string
+		generated string
+		want      string
+		conflicts []Conflict
+	}{
+		{
+			name:      "edited only",
+			previous:  `{"a": 1, "b": 1}`,
+			edited:    `{"a": 2, "b": 1}`,
+			generated: `{"a": 1, "b": 1}`,
+			want:      `{"a": 2, "b": 1}`,
+		},
+		{
+			name:      "generated only",
+			previous:  `{"a": 1, "b": 1}`,
+			edited:    `{"a": 1, "b": 1}`,
+			generated: `{"a": 1, "b": 2}`,
+			want:      `{"a": 1, "b": 2}`,
+		},
+		{
+			name:      "different fields on both sides",
+			previous:  `{"a": 1, "b": 1}`,
+			edited:    `{"a": 2, "b": 1}`,
+			generated: `{"a": 1, "b": 2}`,
+			want:      `{"a": 2, "b": 2}`,
+		},
+		{
+			name:      "same change on both sides",
+			previous:  `{"a": 1}`,
+			edited:    `{"a": 2}`,
+			generated: `{"a": 2}`,
+			want:      `{"a": 2}`,
+		},
+		{
+			name:      "conflict keeps the edited value",
+			previous:  `{"spec": {"replicas": 1}}`,
+			edited:    `{"spec": {"replicas": 2}}`,
+			generated: `{"spec": {"replicas": 3}}`,
+			want:      `{"spec": {"replicas": 2}}`,
+			conflicts: []Conflict{{Path: "spec.replicas", Edited: 2.0, Generated: 3.0}},
+		},
+		{
+			name:      "key deleted by hand",
+			previous:  `{"a": 1, "b": 1}`,
+			edited:    `{"a": 1}`,
+			generated: `{"a": 1, "b": 1}`,
+			want:      `{"a": 1}`,
+		},
+		{
+			name:      "key deleted by the conversion",
+			previous:  `{"a": 1, "b": 1}`,
+			edited:    `{"a": 1, "b": 1}`,
+			generated: `{"a": 1}`,
+			want:      `{"a": 1}`,
+		},
+		{
+			name:      "key deleted by hand and changed by the conversion",
+			previous:  `{"a": 1, "b": 1}`,
+			edited:    `{"a": 1}`,
+			generated: `{"a": 1, "b": 2}`,
+			want:      `{"a": 1}`,
+			conflicts: []Conflict{{Path: "b", Edited: nil, Generated: 2.0}},
+		},
+		{
+			name:      "containers merged by name",
+			previous:  `{"containers": [{"name": "web", "image": "nginx:1"}]}`,
+			edited:    `{"containers": [{"name": "web", "image": "nginx:1", "env": [{"name": "A", "value": "1"}]}, {"name": "log", "image": "fluentd"}]}`,
+			generated: `{"containers": [{"name": "web", "image": "nginx:2"}]}`,
+			want:      `{"containers": [{"name": "web", "image": "nginx:2", "env": [{"name": "A", "value": "1"}]}, {"name": "log", "image": "fluentd"}]}`,
+		},
+		{
+			name:      "other lists conflict as a whole",
+			previous:  `{"args": ["-a"]}`,
+			edited:    `{"args": ["-b"]}`,
+			generated: `{"args": ["-c"]}`,
+			want:      `{"args": ["-b"]}`,
+			conflicts: []Conflict{{Path: "args", Edited: []interface{}{"-b"}, Generated: []interface{}{"-c"}}},
+		},
+	}
+	for _, test := range tests {
+		got, conflicts := ThreeWayMerge(
+			decodeJSON(t, test.previous).(map[string]interface{}),
+			decodeJSON(t, test.edited).(map[string]interface{}),
+			decodeJSON(t, test.generated).(map[string]interface{}),
+		)
+		if want := decodeJSON(t, test.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, want)
+		}
+		if !reflect.DeepEqual(conflicts, test.conflicts) {
+			t.Errorf("%s: got the conflicts %v, want %v", test.name, conflicts, test.conflicts)
+		}
+	}
+}
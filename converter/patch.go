@@ -0,0 +1,344 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// mergeKeys are the keys identifying the items of the lists merged by a
+// strategic merge patch, the first key found in the items is used. The
+// other lists are replaced.
+var mergeKeys = map[string][]string{
+	"containers":       {"name"},
+	"initContainers":   {"name"},
+	"env":              {"name"},
+	"volumes":          {"name"},
+	"volumeMounts":     {"mountPath"},
+	"imagePullSecrets": {"name"},
+	"ports":            {"containerPort", "port"},
+	"hostAliases":      {"ip"},
+}
+
+// StrategicMerge applies a strategic merge patch to a versioned object:
+// maps are merged, null values remove their key, the lists of mergeKeys are
+// merged by key and other values are replaced. A "$patch: replace" map
+// replaces the original and a "$patch: delete" list item removes the item
+// with the same key. The patch is left unchanged.
+func StrategicMerge(object map[string]interface{}, patch map[string]interface{}) map[string]interface{} {
+	merged, _ := strategicMerge("", object, copyValue(patch)).(map[string]interface{})
+	return merged
+}
+
+func strategicMerge(field string, original interface{}, patch interface{}) interface{} {
+	switch patch := patch.(type) {
+	case map[string]interface{}:
+		object, ok := original.(map[string]interface{})
+		if !ok || patch["$patch"] == "replace" {
+			// Merging into an empty map strips the nulls and directives
+			// of the patch at every depth.
+			object = map[string]interface{}{}
+		}
+		for key, value := range patch {
+			if key == "$patch" {
+				continue
+			}
+			if value == nil {
+				delete(object, key)
+				continue
+			}
+			object[key] = strategicMerge(key, object[key], value)
+		}
+		return object
+	case []interface{}:
+		items, ok := original.([]interface{})
+		key := ListMergeKey(field, items, patch)
+		if !ok || key == "" {
+			replaced := make([]interface{}, len(patch))
+			for i, item := range patch {
+				replaced[i] = strategicMerge("", nil, item)
+			}
+			return replaced
+		}
+		for _, patchItem := range patch {
+			patchMap := patchItem.(map[string]interface{})
+			index := -1
+			for i, item := range items {
+				if itemMap, ok := item.(map[string]interface{}); ok && fmt.Sprint(itemMap[key]) == fmt.Sprint(patchMap[key]) {
+					index = i
+					break
+				}
+			}
+			switch {
+			case patchMap["$patch"] == "delete":
+				if index >= 0 {
+					items = append(items[:index], items[index+1:]...)
+				}
+			case index >= 0:
+				items[index] = strategicMerge("", items[index], patchMap)
+			default:
+				items = append(items, strategicMerge("", nil, patchMap))
+			}
+		}
+		return items
+	}
+	return patch
+}
+
+// ListMergeKey returns the key merging the items of the lists of a field in
+// a strategic merge patch, "" if the lists are replaced as a whole.
+func ListMergeKey(field string, lists ...[]interface{}) string {
+	for _, key := range mergeKeys[field] {
+		found := true
+		for _, list := range lists {
+			for _, item := range list {
+				itemMap, ok := item.(map[string]interface{})
+				if !ok || itemMap[key] == nil {
+					found = false
+				}
+			}
+		}
+		if found {
+			return key
+		}
+	}
+	return ""
+}
+
+// JSONPatch applies a JSON patch, a list of RFC 6902 operations, to a
+// versioned object. The object is left partly patched when an operation
+// fails.
+func JSONPatch(object map[string]interface{}, patch []interface{}) (map[string]interface{}, error) {
+	var document interface{} = object
+	for i, item := range patch {
+		operation, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("operation %d is not a map", i)
+		}
+		op, _ := operation["op"].(string)
+		path, err := jsonPointer(operation["path"])
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %v", i, err)
+		}
+		value, hasValue := operation["value"]
+		value = copyValue(value)
+		switch op {
+		case "add", "replace", "test":
+			if !hasValue {
+				return nil, fmt.Errorf("operation %d: %s needs a value", i, op)
+			}
+		}
+		switch op {
+		case "add":
+			document, err = pointerAdd(document, path, value, false)
+		case "replace":
+			document, err = pointerAdd(document, path, value, true)
+		case "remove":
+			document, _, err = pointerRemove(document, path)
+		case "move", "copy":
+			var from []string
+			if from, err = jsonPointer(operation["from"]); err != nil {
+				break
+			}
+			if op == "move" {
+				document, value, err = pointerRemove(document, from)
+			} else {
+				value, err = pointerGet(document, from)
+				value = copyValue(value)
+			}
+			if err == nil {
+				document, err = pointerAdd(document, path, value, false)
+			}
+		case "test":
+			var current interface{}
+			if current, err = pointerGet(document, path); err == nil && !reflect.DeepEqual(current, value) {
+				err = fmt.Errorf("test of %s failed, the value is %v", operation["path"], current)
+			}
+		default:
+			err = fmt.Errorf("unknown op %q", op)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %v", i, err)
+		}
+	}
+	object, ok := document.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("the patched document is not an object")
+	}
+	return object, nil
+}
+
+// jsonPointer splits an RFC 6901 JSON pointer in its unescaped tokens.
+func jsonPointer(pointer interface{}) ([]string, error) {
+	path, ok := pointer.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid path %v", pointer)
+	}
+	if path == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path %s does not start with /", path)
+	}
+	tokens := strings.Split(path[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+func pointerIndex(token string, length int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index >= length {
+		return 0, fmt.Errorf("invalid index %s", token)
+	}
+	return index, nil
+}
+
+func pointerGet(document interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := document.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("missing key %s", token)
+			}
+			document = value
+		case []interface{}:
+			index, err := pointerIndex(token, len(node))
+			if err != nil {
+				return nil, err
+			}
+			document = node[index]
+		default:
+			return nil, fmt.Errorf("cannot look up %s in a %T", token, document)
+		}
+	}
+	return document, nil
+}
+
+// pointerAdd adds value at path, or replaces the value found there.
+func pointerAdd(document interface{}, path []string, value interface{}, replace bool) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	token, last := path[0], len(path) == 1
+	switch node := document.(type) {
+	case map[string]interface{}:
+		child, ok := node[token]
+		if !ok && (replace || !last) {
+			return nil, fmt.Errorf("missing key %s", token)
+		}
+		if last {
+			node[token] = value
+			return node, nil
+		}
+		child, err := pointerAdd(child, path[1:], value, replace)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = child
+		return node, nil
+	case []interface{}:
+		if last && !replace {
+			index := len(node)
+			if token != "-" {
+				var err error
+				if index, err = pointerIndex(token, len(node)+1); err != nil {
+					return nil, err
+				}
+			}
+			node = append(node, nil)
+			copy(node[index+1:], node[index:])
+			node[index] = value
+			return node, nil
+		}
+		index, err := pointerIndex(token, len(node))
+		if err != nil {
+			return nil, err
+		}
+		if last {
+			node[index] = value
+			return node, nil
+		}
+		if node[index], err = pointerAdd(node[index], path[1:], value, replace); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+	return nil, fmt.Errorf("cannot set %s in a %T", token, document)
+}
+
+// pointerRemove removes the value at path and returns it.
+func pointerRemove(document interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("cannot remove the whole object")
+	}
+	token, last := path[0], len(path) == 1
+	switch node := document.(type) {
+	case map[string]interface{}:
+		child, ok := node[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("missing key %s", token)
+		}
+		if last {
+			delete(node, token)
+			return node, child, nil
+		}
+		child, removed, err := pointerRemove(child, path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		node[token] = child
+		return node, removed, nil
+	case []interface{}:
+		index, err := pointerIndex(token, len(node))
+		if err != nil {
+			return nil, nil, err
+		}
+		if last {
+			removed := node[index]
+			return append(node[:index], node[index+1:]...), removed, nil
+		}
+		child, removed, err := pointerRemove(node[index], path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		node[index] = child
+		return node, removed, nil
+	}
+	return nil, nil, fmt.Errorf("cannot remove %s from a %T", token, document)
+}
+
+// copyValue returns a deep copy of a JSON value.
+func copyValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			copied[key] = copyValue(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for i, item := range value {
+			copied[i] = copyValue(item)
+		}
+		return copied
+	}
+	return value
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+
+	"k8s.io/kubernetes/pkg/labels"
+)
+
+// PatchTarget selects the objects a patch applies to. Empty fields match
+// every object.
+type PatchTarget struct {
+	Kind          string `json:"kind,omitempty"`
+	Name          string `json:"name,omitempty"`
+	LabelSelector string `json:"labelSelector,omitempty"`
+}
+
+// Patch is a strategic merge patch, when Patch is a map, or a JSON patch,
+// when it is a list of operations, applied to the objects of Target.
+type Patch struct {
+	Target PatchTarget `json:"target"`
+	Patch  interface{} `json:"patch"`
+}
+
+// patchFile is a file of patches applied in order.
+type patchFile struct {
+	path     string
+	patches  []Patch
+	selector []labels.Selector
+}
+
+// LoadPatches reads a patch file, the patches listed under patches, as a
+// transformer applying them in order.
+func LoadPatches(path string) (Transformer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the patch file %s: %v", path, err)
+	}
+	var file struct {
+		Patches []Patch `json:"patches"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("Failed to parse the patch file %s: %v", path, err)
+	}
+	f := &patchFile{path: path, patches: file.Patches}
+	for i, patch := range file.Patches {
+		switch patch.Patch.(type) {
+		case map[string]interface{}, []interface{}:
+		default:
+			return nil, fmt.Errorf("%s: patch %d is neither a map nor a list of JSON patch operations", path, i+1)
+		}
+		selector, err := labels.Parse(patch.Target.LabelSelector)
+		if err != nil {
+			return nil, fmt.Errorf("%s: patch %d: invalid label selector: %v", path, i+1, err)
+		}
+		f.selector = append(f.selector, selector)
+	}
+	return f, nil
+}
+
+// Transform applies the patches to the objects they target.
+func (f *patchFile) Transform(objects []Object) ([]Object, []string, error) {
+	var warnings []string
+	for i, patch := range f.patches {
+		matched := false
+		for j := range objects {
+			object := &objects[j]
+			if !f.matches(i, object.Versioned) {
+				continue
+			}
+			matched = true
+			switch fragment := patch.Patch.(type) {
+			case map[string]interface{}:
+				object.Versioned = StrategicMerge(object.Versioned, fragment)
+			case []interface{}:
+				patched, err := JSONPatch(object.Versioned, fragment)
+				if err != nil {
+					return nil, warnings, fmt.Errorf("%s: patch %d of %s %s: %v", f.path, i+1, object.Versioned["kind"], object.Name, err)
+				}
+				object.Versioned = patched
+			}
+		}
+		if !matched {
+			warnings = append(warnings, fmt.Sprintf("%s: patch %d matches no object", f.path, i+1))
+		}
+	}
+	return objects, warnings, nil
+}
+
+func (f *patchFile) matches(i int, object map[string]interface{}) bool {
+	target := f.patches[i].Target
+	if target.Kind != "" && target.Kind != object["kind"] {
+		return false
+	}
+	if name, _ := GetField(object, "metadata", "name").(string); target.Name != "" && target.Name != name {
+		return false
+	}
+	set := labels.Set{}
+	objectLabels, _ := GetField(object, "metadata", "labels").(map[string]interface{})
+	for key, value := range objectLabels {
+		set[key] = fmt.Sprint(value)
+	}
+	return f.selector[i].Matches(set)
+}
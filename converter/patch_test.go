@@ -0,0 +1,238 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decodeJSON(t *testing.T, data string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		t.Fatalf("invalid test JSON %s: %v", data, err)
+	}
+	return value
+}
+
+func TestStrategicMerge(t *testing.T) {
+	tests := []struct {
+		name   string
+		object string
+		patch  string
+		want   string
+	}{
+		{
+			name:   "maps are merged",
+			object: `{"metadata": {"name": "web", "labels": {"a": "1"}}}`,
+			patch:  `{"metadata": {"labels": {"b": "2"}}}`,
+			want:   `{"metadata": {"name": "web", "labels": {"a": "1", "b": "2"}}}`,
+		},
+		{
+			name:   "null removes the key",
+			object: `{"metadata": {"name": "web", "labels": {"a": "1"}}}`,
+			patch:  `{"metadata": {"labels": null}}`,
+			want:   `{"metadata": {"name": "web"}}`,
+		},
+		{
+			name:   "nulls are stripped from new maps",
+			object: `{"kind": "Service"}`,
+			patch:  `{"metadata": {"annotations": {"a": null, "b": "1"}}}`,
+			want:   `{"kind": "Service", "metadata": {"annotations": {"b": "1"}}}`,
+		},
+		{
+			name:   "directives are stripped from new maps",
+			object: `{"kind": "Service"}`,
+			patch:  `{"spec": {"selector": {"$patch": "replace", "a": "1"}}}`,
+			want:   `{"kind": "Service", "spec": {"selector": {"a": "1"}}}`,
+		},
+		{
+			name:   "nulls are stripped from new list items",
+			object: `{"containers": [{"name": "web"}]}`,
+			patch:  `{"containers": [{"name": "log", "resources": {"limits": null}}]}`,
+			want:   `{"containers": [{"name": "web"}, {"name": "log", "resources": {}}]}`,
+		},
+		{
+			name:   "$patch replace replaces the map",
+			object: `{"spec": {"selector": {"a": "1", "b": "2"}}}`,
+			patch:  `{"spec": {"selector": {"$patch": "replace", "c": "3"}}}`,
+			want:   `{"spec": {"selector": {"c": "3"}}}`,
+		},
+		{
+			name:   "containers are merged by name",
+			object: `{"containers": [{"name": "web", "image": "nginx"}, {"name": "log", "image": "fluentd"}]}`,
+			patch:  `{"containers": [{"name": "log", "image": "fluent-bit"}, {"name": "proxy", "image": "envoy"}]}`,
+			want:   `{"containers": [{"name": "web", "image": "nginx"}, {"name": "log", "image": "fluent-bit"}, {"name": "proxy", "image": "envoy"}]}`,
+		},
+		{
+			name:   "$patch delete removes the item with the key",
+			object: `{"containers": [{"name": "web", "image": "nginx"}, {"name": "log", "image": "fluentd"}]}`,
+			patch:  `{"containers": [{"name": "log", "$patch": "delete"}]}`,
+			want:   `{"containers": [{"name": "web", "image": "nginx"}]}`,
+		},
+		{
+			name:   "container ports are merged by containerPort",
+			object: `{"ports": [{"containerPort": 80, "protocol": "TCP"}, {"containerPort": 443}]}`,
+			patch:  `{"ports": [{"containerPort": 80, "name": "http"}]}`,
+			want:   `{"ports": [{"containerPort": 80, "protocol": "TCP", "name": "http"}, {"containerPort": 443}]}`,
+		},
+		{
+			name:   "Service ports are merged by port",
+			object: `{"ports": [{"port": 80, "targetPort": 8080}]}`,
+			patch:  `{"ports": [{"port": 80, "nodePort": 30080}]}`,
+			want:   `{"ports": [{"port": 80, "targetPort": 8080, "nodePort": 30080}]}`,
+		},
+		{
+			name:   "volumeMounts are merged by mountPath",
+			object: `{"volumeMounts": [{"name": "data", "mountPath": "/data"}, {"name": "data", "mountPath": "/backup"}]}`,
+			patch:  `{"volumeMounts": [{"mountPath": "/backup", "readOnly": true}]}`,
+			want:   `{"volumeMounts": [{"name": "data", "mountPath": "/data"}, {"name": "data", "mountPath": "/backup", "readOnly": true}]}`,
+		},
+		{
+			name:   "other lists are replaced",
+			object: `{"args": ["-a", "-b"], "httpHeaders": [{"name": "Host", "value": "a"}]}`,
+			patch:  `{"args": ["-c"], "httpHeaders": [{"name": "Accept", "value": "b"}]}`,
+			want:   `{"args": ["-c"], "httpHeaders": [{"name": "Accept", "value": "b"}]}`,
+		},
+	}
+	for _, test := range tests {
+		patch := decodeJSON(t, test.patch).(map[string]interface{})
+		before := copyValue(patch)
+		got := StrategicMerge(decodeJSON(t, test.object).(map[string]interface{}), patch)
+		if want := decodeJSON(t, test.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, want)
+		}
+		if !reflect.DeepEqual(patch, before) {
+			t.Errorf("%s: the patch was changed to %v", test.name, patch)
+		}
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		object string
+		patch  string
+		want   string
+		fails  bool
+	}{
+		{
+			name:   "add a key",
+			object: `{"a": {}}`,
+			patch:  `[{"op": "add", "path": "/a/b", "value": 1}]`,
+			want:   `{"a": {"b": 1}}`,
+		},
+		{
+			name:   "add inserts at an index",
+			object: `{"a": [1, 3]}`,
+			patch:  `[{"op": "add", "path": "/a/1", "value": 2}]`,
+			want:   `{"a": [1, 2, 3]}`,
+		},
+		{
+			name:   "add appends with -",
+			object: `{"a": [1, 2]}`,
+			patch:  `[{"op": "add", "path": "/a/-", "value": 3}]`,
+			want:   `{"a": [1, 2, 3]}`,
+		},
+		{
+			name:   "add past the end fails",
+			object: `{"a": [1]}`,
+			patch:  `[{"op": "add", "path": "/a/2", "value": 3}]`,
+			fails:  true,
+		},
+		{
+			name:   "remove a key",
+			object: `{"a": 1, "b": 2}`,
+			patch:  `[{"op": "remove", "path": "/a"}]`,
+			want:   `{"b": 2}`,
+		},
+		{
+			name:   "remove an item",
+			object: `{"a": [1, 2, 3]}`,
+			patch:  `[{"op": "remove", "path": "/a/1"}]`,
+			want:   `{"a": [1, 3]}`,
+		},
+		{
+			name:   "remove a missing key fails",
+			object: `{"a": 1}`,
+			patch:  `[{"op": "remove", "path": "/b"}]`,
+			fails:  true,
+		},
+		{
+			name:   "replace a value",
+			object: `{"a": {"b": 1}}`,
+			patch:  `[{"op": "replace", "path": "/a/b", "value": 2}]`,
+			want:   `{"a": {"b": 2}}`,
+		},
+		{
+			name:   "replace a missing key fails",
+			object: `{"a": {}}`,
+			patch:  `[{"op": "replace", "path": "/a/b", "value": 2}]`,
+			fails:  true,
+		},
+		{
+			name:   "move a value",
+			object: `{"a": {"b": 1}, "c": {}}`,
+			patch:  `[{"op": "move", "from": "/a/b", "path": "/c/d"}]`,
+			want:   `{"a": {}, "c": {"d": 1}}`,
+		},
+		{
+			name:   "copy a value",
+			object: `{"a": {"b": [1]}}`,
+			patch:  `[{"op": "copy", "from": "/a/b", "path": "/c"}, {"op": "add", "path": "/c/-", "value": 2}]`,
+			want:   `{"a": {"b": [1]}, "c": [1, 2]}`,
+		},
+		{
+			name:   "test passes",
+			object: `{"a": {"b": "x"}}`,
+			patch:  `[{"op": "test", "path": "/a/b", "value": "x"}, {"op": "add", "path": "/c", "value": 1}]`,
+			want:   `{"a": {"b": "x"}, "c": 1}`,
+		},
+		{
+			name:   "test fails",
+			object: `{"a": {"b": "x"}}`,
+			patch:  `[{"op": "test", "path": "/a/b", "value": "y"}]`,
+			fails:  true,
+		},
+		{
+			name:   "escaped pointers",
+			object: `{"metadata": {"annotations": {"example.com/a~b": "1"}}}`,
+			patch:  `[{"op": "replace", "path": "/metadata/annotations/example.com~1a~0b", "value": "2"}]`,
+			want:   `{"metadata": {"annotations": {"example.com/a~b": "2"}}}`,
+		},
+		{
+			name:   "unknown op fails",
+			object: `{}`,
+			patch:  `[{"op": "merge", "path": "/a"}]`,
+			fails:  true,
+		},
+	}
+	for _, test := range tests {
+		got, err := JSONPatch(decodeJSON(t, test.object).(map[string]interface{}), decodeJSON(t, test.patch).([]interface{}))
+		if test.fails {
+			if err == nil {
+				t.Errorf("%s: got %v, want an error", test.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if want := decodeJSON(t, test.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2015 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"encoding/json"
+
+	"github.com/docker/libcompose/config"
+	"github.com/docker/libcompose/project"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/api/v1"
+	"k8s.io/kubernetes/pkg/apis/apps"
+	appsv1alpha1 "k8s.io/kubernetes/pkg/apis/apps/v1alpha1"
+	"k8s.io/kubernetes/pkg/apis/batch"
+	batchv2alpha1 "k8s.io/kubernetes/pkg/apis/batch/v2alpha1"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/apis/extensions/v1beta1"
+	"k8s.io/kubernetes/pkg/runtime"
+	k8sjson "k8s.io/kubernetes/pkg/runtime/serializer/json"
+)
+
+// encodeVersions are the group versions internal objects are converted to
+// before adjustForKubeVersion picks the ones of the target release.
+var encodeVersions = []unversioned.GroupVersion{
+	v1.SchemeGroupVersion,
+	v1beta1.SchemeGroupVersion,
+	appsv1alpha1.SchemeGroupVersion,
+	batchv2alpha1.SchemeGroupVersion,
+}
+
+func init() {
+	api.AddToScheme(api.Scheme)
+	v1.AddToScheme(api.Scheme)
+	extensions.AddToScheme(api.Scheme)
+	v1beta1.AddToScheme(api.Scheme)
+	apps.AddToScheme(api.Scheme)
+	appsv1alpha1.AddToScheme(api.Scheme)
+	batch.AddToScheme(api.Scheme)
+	batchv2alpha1.AddToScheme(api.Scheme)
+}
+
+// versionedObject converts an internal object to its versioned representation
+// on the target Kubernetes release.
+func (c *conversion) versionedObject(object runtime.Object) (map[string]interface{}, error) {
+	// The internal types are not the wire format, convert them to their
+	// versioned counterpart through the scheme.
+	serializer := k8sjson.NewSerializer(k8sjson.DefaultMetaFactory, api.Scheme, api.Scheme, false)
+	encoder := api.Codecs.CodecForVersions(serializer, nil, encodeVersions, nil)
+	data, err := runtime.Encode(encoder, object)
+	if err != nil {
+		return nil, err
+	}
+	var versioned map[string]interface{}
+	if err := json.Unmarshal(data, &versioned); err != nil {
+		return nil, err
+	}
+	// The status is set by the cluster.
+	delete(versioned, "status")
+	pruneEmpty(versioned)
+	c.adjustForKubeVersion(versioned)
+	return versioned, nil
+}
+
+// pruneNulls removes the fields left unset by the versioned types.
+func pruneNulls(object interface{}) {
+	switch object := object.(type) {
+	case map[string]interface{}:
+		for key, value := range object {
+			if value == nil {
+				delete(object, key)
+				continue
+			}
+			pruneNulls(value)
+		}
+	case []interface{}:
+		for _, item := range object {
+			pruneNulls(item)
+		}
+	}
+}
+
+// keptEmpty are the fields meaningful even when empty.
+var keptEmpty = map[string]bool{
+	"emptyDir": true,
+}
+
+// pruneEmpty removes the fields left unset by the versioned types: nulls,
+// empty maps and empty lists. It reports whether the value is empty.
+func pruneEmpty(object interface{}) bool {
+	switch object := object.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		for key, value := range object {
+			if pruneEmpty(value) && !keptEmpty[key] {
+				delete(object, key)
+			}
+		}
+		return len(object) == 0
+	case []interface{}:
+		for _, item := range object {
+			pruneEmpty(item)
+		}
+		return len(object) == 0
+	}
+	return false
+}
+
+// convertObject converts an object to the target release. With answers the
+// namespace is set to the NAMESPACE answer.
+func (c *conversion) convertObject(shortName string, sufix string, service *config.ServiceConfig, object runtime.Object) Object {
+	versioned, err := c.versionedObject(object)
+	if err != nil {
+		fail("Failed to marshal file %s-%s: %v", shortName, sufix, err)
+	}
+	if metadata, ok := versioned["metadata"].(map[string]interface{}); ok && c.options.Answers != nil && metadata["namespace"] == "${NAMESPACE}" {
+		metadata["namespace"] = c.options.Answers["NAMESPACE"]
+	}
+	return Object{Name: shortName, Suffix: sufix, Service: service, Versioned: versioned}
+}
+
+// convertDockerCompose converts every service of the compose project to
+// its controller and service. The services with errors, or with sidekicks
+// with errors, are left out.
+func (c *conversion) convertDockerCompose(dockerCompose *project.Project, rancherCompose *RancherCompose) []Object {
+	var generated []Object
+	primaries := c.sidekickPrimaries(dockerCompose)
+	aliases := aliasLabels(dockerCompose)
+	for _, name := range dockerCompose.ServiceConfigs.Keys() {
+		service, ok := dockerCompose.ServiceConfigs.Get(name)
+		if !ok {
+			fail("Failed to get key %s from config", name)
+		}
+		if primary, ok := primaries[name]; ok {
+			// Sidekicks run in the pod of their primary service.
+			if c.extensions.services[name] != nil {
+				c.warnf("service %s: the %s section of a sidekick is left out, set it on %s", name, extensionKey, primary)
+			}
+			continue
+		}
+		objects := c.convertService(name, service, dockerCompose, rancherCompose, primaries, aliases[name])
+		failed := c.failed[name]
+		for sidekick, primary := range primaries {
+			failed = failed || primary == name && c.failed[sidekick]
+		}
+		if !failed {
+			generated = append(generated, objects...)
+		}
+	}
+	return generated
+}
+
+// convertService converts a service to its objects patched by its
+// x-kubernetes sections, recording the error stopping it.
+func (c *conversion) convertService(name string, service *config.ServiceConfig, dockerCompose *project.Project, rancherCompose *RancherCompose, primaries map[string]string, aliases map[string]string) []Object {
+	defer c.recoverService(name)
+	objects := c.buildService(name, service, dockerCompose, rancherCompose, primaries, aliases)
+	c.applyExtensions(name, objects)
+	return objects
+}
+
+// buildService converts a service to its objects.
+func (c *conversion) buildService(name string, service *config.ServiceConfig, dockerCompose *project.Project, rancherCompose *RancherCompose, primaries map[string]string, aliases map[string]string) (generated []Object) {
+	shortName := name
+	if len(name) > 24 {
+		shortName = name[0:24]
+	}
+
+	// Rancher load balancers and DNS entries do not run pods.
+	switch {
+	case isLoadBalancer(service):
+		return c.createLoadBalancer(name, shortName, service, rancherCompose)
+	case isExternalService(service):
+		return c.createExternalService(name, shortName, service, rancherCompose)
+	case isDNSService(service):
+		return []Object{c.createAliasService(name, shortName, service, dockerCompose)}
+	}
+
+	template := c.createPodTemplate(name, shortName, service, rancherCompose)
+	for label, value := range aliases {
+		template.Labels[label] = value
+	}
+	c.configureSidekicks(name, template, dockerCompose, primaries, rancherCompose)
+	controller, sufix := c.createController(name, shortName, service, template, rancherCompose)
+	generated = append(generated, c.convertObject(shortName, sufix, service, controller))
+
+	srv := createService(shortName, service, template)
+	generated = append(generated, c.convertObject(shortName, "srv", service, srv))
+	return generated
+}
+
+// PodTemplateOf returns the pod template of a versioned controller, or nil.
+func PodTemplateOf(object map[string]interface{}) map[string]interface{} {
+	path := []string{"spec", "template"}
+	if object["kind"] == "CronJob" || object["kind"] == "ScheduledJob" {
+		path = []string{"spec", "jobTemplate", "spec", "template"}
+	}
+	template, _ := GetField(object, path...).(map[string]interface{})
+	return template
+}
+
+// PodSpecOf returns the pod spec of a versioned controller, or nil.
+func PodSpecOf(object map[string]interface{}) map[string]interface{} {
+	podSpec, _ := GetField(PodTemplateOf(object), "spec").(map[string]interface{})
+	return podSpec
+}
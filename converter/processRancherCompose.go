@@ -0,0 +1,213 @@
+/*
+Copyright 2015 German Ramos. All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/util/intstr"
+)
+
+func configureScale(name string, rancherCompose *RancherCompose) int32 {
+	if service := rancherCompose.service(name); service != nil {
+		return rancherCompose.intValue(service.Scale, 1, rancherCompose.servicePath(name, "scale")...)
+	}
+	return 1
+}
+
+// configureUpgradeStrategy translates the upgrade_strategy of the service
+// into a rolling update. Rancher upgrades batch_size containers at a time,
+// starting the new ones first with start_first, and waits interval_millis
+// between batches.
+func configureUpgradeStrategy(name string, rancherCompose *RancherCompose) (extensions.DeploymentStrategy, int32) {
+	service := rancherCompose.service(name)
+	if service == nil || service.UpgradeStrategy == nil {
+		return extensions.DeploymentStrategy{}, 0
+	}
+	upgradeStrategy := service.UpgradeStrategy
+	batchSizePath := rancherCompose.servicePath(name, "upgrade_strategy", "batch_size")
+	batchSize := rancherCompose.intValue(upgradeStrategy.BatchSize, 1, batchSizePath...)
+	if batchSize < 1 {
+		// Kubernetes rejects a rolling update with no surge nor
+		// unavailable pod.
+		rancherCompose.fatalf(batchSizePath, "expected at least 1, got %d", batchSize)
+	}
+	intervalPath := rancherCompose.servicePath(name, "upgrade_strategy", "interval_millis")
+	interval := rancherCompose.intValue(upgradeStrategy.IntervalMillis, 2000, intervalPath...)
+	if interval < 0 {
+		rancherCompose.fatalf(intervalPath, "expected 0 or more milliseconds, got %d", interval)
+	}
+	startFirst := rancherCompose.boolValue(upgradeStrategy.StartFirst, rancherCompose.servicePath(name, "upgrade_strategy", "start_first")...)
+
+	rollingUpdate := &extensions.RollingUpdateDeployment{
+		MaxSurge:       intstr.FromInt(0),
+		MaxUnavailable: intstr.FromInt(int(batchSize)),
+	}
+	if startFirst {
+		rollingUpdate.MaxSurge, rollingUpdate.MaxUnavailable = rollingUpdate.MaxUnavailable, rollingUpdate.MaxSurge
+	}
+	strategy := extensions.DeploymentStrategy{
+		Type:          extensions.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: rollingUpdate,
+	}
+	return strategy, (interval + 999) / 1000
+}
+
+// getSecondsHealthCheckValue reads a duration in milliseconds, rounded up to
+// whole seconds since probes do not go below.
+func getSecondsHealthCheckValue(name string, rancherCompose *RancherCompose, value *RancherValue, key string) int32 {
+	return (rancherCompose.intValue(value, 0, rancherCompose.servicePath(name, "health_check", key)...) + 999) / 1000
+}
+
+// splitRequestLine splits a health_check request_line into its words,
+// double quoted words may contain spaces.
+func splitRequestLine(requestLine string) []string {
+	var words []string
+	var word []rune
+	quoted := false
+	for _, r := range requestLine {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ' ' && !quoted:
+			if len(word) > 0 {
+				words = append(words, string(word))
+				word = nil
+			}
+		default:
+			word = append(word, r)
+		}
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	return words
+}
+
+// parseRequestLine turns a request_line such as
+// GET "/ping" "HTTP/1.0\r\nHost: example.com" into an HTTP get action. The
+// headers follow the protocol version, separated by \r\n.
+func (c *conversion) parseRequestLine(name string, requestLine string, port intstr.IntOrString) *api.HTTPGetAction {
+	action := &api.HTTPGetAction{Path: "/", Port: port}
+	words := splitRequestLine(requestLine)
+	if len(words) > 0 && !strings.HasPrefix(words[0], "/") {
+		method := strings.ToUpper(words[0])
+		if method != "GET" && method != "HEAD" {
+			c.warnf("service %s: Kubernetes HTTP probes always use GET, not %s", name, method)
+		}
+		words = words[1:]
+	}
+	if len(words) > 0 {
+		action.Path = words[0]
+		words = words[1:]
+	}
+	if len(words) > 0 {
+		lines := strings.Split(strings.Replace(strings.Join(words, " "), `\r\n`, "\r\n", -1), "\r\n")
+		for _, line := range lines[1:] {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			parts := strings.SplitN(line, ":", 2)
+			if len(parts) != 2 {
+				c.errorf(name, "health_check.request_line", "invalid header %q", line)
+				continue
+			}
+			action.HTTPHeaders = append(action.HTTPHeaders, api.HTTPHeader{
+				Name:  strings.TrimSpace(parts[0]),
+				Value: strings.TrimSpace(parts[1]),
+			})
+		}
+	}
+	return action
+}
+
+// configureHealthCheck returns the liveness and readiness probes of the
+// rancher-compose health_check of the service. Rancher always stops routing
+// to unhealthy containers, the recreate strategies also replace them.
+func (c *conversion) configureHealthCheck(name string, rancherCompose *RancherCompose) (*api.Probe, *api.Probe) {
+	service := rancherCompose.service(name)
+	if service == nil || service.HealthCheck == nil {
+		return nil, nil
+	}
+	rancherHealhCheck := service.HealthCheck
+	path := func(key string) []string {
+		return rancherCompose.servicePath(name, "health_check", key)
+	}
+	check := &api.Probe{
+		InitialDelaySeconds: getSecondsHealthCheckValue(name, rancherCompose, rancherHealhCheck.InitializingTimeout, "initializing_timeout"),
+		TimeoutSeconds:      getSecondsHealthCheckValue(name, rancherCompose, rancherHealhCheck.ResponseTimeout, "response_timeout"),
+		PeriodSeconds:       getSecondsHealthCheckValue(name, rancherCompose, rancherHealhCheck.Interval, "interval"),
+		SuccessThreshold:    rancherCompose.intValue(rancherHealhCheck.HealthyThreshold, 0, path("healthy_threshold")...),
+		FailureThreshold:    rancherCompose.intValue(rancherHealhCheck.UnhealthyThreshold, 0, path("unhealthy_threshold")...),
+	}
+	port := intstr.FromInt(int(rancherCompose.intValue(rancherHealhCheck.Port, 0, path("port")...)))
+	if strings.TrimSpace(rancherHealhCheck.RequestLine) == "" {
+		check.TCPSocket = &api.TCPSocketAction{
+			Port: port,
+		}
+	} else {
+		check.HTTPGet = c.parseRequestLine(name, rancherHealhCheck.RequestLine, port)
+	}
+
+	switch rancherHealhCheck.Strategy {
+	case "", "none":
+		return nil, check
+	case "recreate", "recreateOnQuorum":
+		if rancherHealhCheck.Strategy == "recreateOnQuorum" {
+			c.warnf("service %s: Kubernetes has no quorum for liveness probes, recreate_on_quorum_strategy_config is left out", name)
+		}
+		// Liveness probes only accept a success threshold of 1.
+		liveness := *check
+		liveness.SuccessThreshold = 0
+		return &liveness, check
+	}
+	rancherCompose.fatalf(path("strategy"), "unknown strategy %s, expected none, recreate or recreateOnQuorum", rancherHealhCheck.Strategy)
+	return nil, nil
+}
+
+// Questions returns the catalog questions in the order they are asked.
+func (f *RancherCompose) Questions() []RancherQuestion {
+	var questions []RancherQuestion
+	if f.Catalog == nil {
+		return questions
+	}
+	for _, question := range f.Catalog.Questions {
+		if question.Variable != "" {
+			questions = append(questions, question)
+		}
+	}
+	return questions
+}
+
+// DefaultValue returns the default of the question, the zero value of its
+// type when it has none. Defaults of text questions are strings.
+func (q RancherQuestion) DefaultValue() interface{} {
+	if q.Default == nil {
+		switch q.Type {
+		case "int":
+			return 0
+		case "boolean":
+			return false
+		}
+		return ""
+	}
+	if _, ok := q.Default.(string); !ok && q.Type != "int" && q.Type != "boolean" {
+		return fmt.Sprint(q.Default)
+	}
+	return q.Default
+}
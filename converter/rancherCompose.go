@@ -0,0 +1,414 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// RancherCompose is a parsed rancher-compose.yml. Version 1 files list
+// the services at the top level, version 2 files under services, next to a
+// catalog or .catalog section.
+type RancherCompose struct {
+	Version  string                     `yaml:"version,omitempty"`
+	Catalog  *RancherCatalog            `yaml:".catalog,omitempty"`
+	Services map[string]*RancherService `yaml:"services,omitempty"`
+
+	// path and data locate the keys of errors in the file, nested is set
+	// when the services are listed under services and catalogKey is the
+	// key of the catalog section. answers interpolate the values.
+	path       string
+	data       []byte
+	nested     bool
+	catalogKey string
+	answers    map[string]string
+}
+
+// RancherCatalog is the .catalog section of a catalog template.
+type RancherCatalog struct {
+	Name                  string                 `yaml:"name,omitempty"`
+	Version               string                 `yaml:"version,omitempty"`
+	Description           string                 `yaml:"description,omitempty"`
+	UUID                  string                 `yaml:"uuid,omitempty"`
+	MinimumRancherVersion string                 `yaml:"minimum_rancher_version,omitempty"`
+	MaximumRancherVersion string                 `yaml:"maximum_rancher_version,omitempty"`
+	UpgradeFrom           string                 `yaml:"upgrade_from,omitempty"`
+	Questions             []RancherQuestion      `yaml:"questions,omitempty"`
+	Extra                 map[string]interface{} `yaml:",inline"`
+}
+
+// RancherQuestion is a question of the .catalog section.
+type RancherQuestion struct {
+	Variable    string                 `yaml:"variable,omitempty"`
+	Label       string                 `yaml:"label,omitempty"`
+	Description string                 `yaml:"description,omitempty"`
+	Type        string                 `yaml:"type,omitempty"`
+	Required    bool                   `yaml:"required,omitempty"`
+	Default     interface{}            `yaml:"default,omitempty"`
+	Group       string                 `yaml:"group,omitempty"`
+	Options     []string               `yaml:"options,omitempty"`
+	Min         *int                   `yaml:"min,omitempty"`
+	Max         *int                   `yaml:"max,omitempty"`
+	MinLength   *int                   `yaml:"min_length,omitempty"`
+	MaxLength   *int                   `yaml:"max_length,omitempty"`
+	Extra       map[string]interface{} `yaml:",inline"`
+}
+
+// RancherService is the rancher-compose entry of a service.
+type RancherService struct {
+	Scale           *RancherValue           `yaml:"scale,omitempty"`
+	ScaleMin        *RancherValue           `yaml:"scale_min,omitempty"`
+	ScaleMax        *RancherValue           `yaml:"scale_max,omitempty"`
+	ScaleIncrement  *RancherValue           `yaml:"scale_increment,omitempty"`
+	RetainIP        *RancherValue           `yaml:"retain_ip,omitempty"`
+	StartOnCreate   *RancherValue           `yaml:"start_on_create,omitempty"`
+	DrainTimeoutMs  *RancherValue           `yaml:"drain_timeout_ms,omitempty"`
+	HealthCheck     *RancherHealthCheck     `yaml:"health_check,omitempty"`
+	UpgradeStrategy *RancherUpgradeStrategy `yaml:"upgrade_strategy,omitempty"`
+	LBConfig        *RancherLBConfig        `yaml:"lb_config,omitempty"`
+	ExternalIPs     []string                `yaml:"external_ips,omitempty"`
+	Hostname        string                  `yaml:"hostname,omitempty"`
+	Metadata        map[string]interface{}  `yaml:"metadata,omitempty"`
+	Extra           map[string]interface{}  `yaml:",inline"`
+}
+
+// RancherHealthCheck is the health_check of a service. Durations are in
+// milliseconds.
+type RancherHealthCheck struct {
+	Port                           *RancherValue          `yaml:"port,omitempty"`
+	Interval                       *RancherValue          `yaml:"interval,omitempty"`
+	InitializingTimeout            *RancherValue          `yaml:"initializing_timeout,omitempty"`
+	ReinitializingTimeout          *RancherValue          `yaml:"reinitializing_timeout,omitempty"`
+	ResponseTimeout                *RancherValue          `yaml:"response_timeout,omitempty"`
+	HealthyThreshold               *RancherValue          `yaml:"healthy_threshold,omitempty"`
+	UnhealthyThreshold             *RancherValue          `yaml:"unhealthy_threshold,omitempty"`
+	RequestLine                    string                 `yaml:"request_line,omitempty"`
+	Strategy                       string                 `yaml:"strategy,omitempty"`
+	RecreateOnQuorumStrategyConfig map[string]interface{} `yaml:"recreate_on_quorum_strategy_config,omitempty"`
+	Extra                          map[string]interface{} `yaml:",inline"`
+}
+
+// RancherUpgradeStrategy is the upgrade_strategy of a service.
+type RancherUpgradeStrategy struct {
+	StartFirst     *RancherValue          `yaml:"start_first,omitempty"`
+	BatchSize      *RancherValue          `yaml:"batch_size,omitempty"`
+	IntervalMillis *RancherValue          `yaml:"interval_millis,omitempty"`
+	Extra          map[string]interface{} `yaml:",inline"`
+}
+
+// RancherLBConfig is the lb_config of a load balancer.
+type RancherLBConfig struct {
+	Certs            []string               `yaml:"certs,omitempty"`
+	DefaultCert      string                 `yaml:"default_cert,omitempty"`
+	PortRules        []RancherPortRule      `yaml:"port_rules,omitempty"`
+	Config           string                 `yaml:"config,omitempty"`
+	StickinessPolicy map[string]interface{} `yaml:"stickiness_policy,omitempty"`
+	Extra            map[string]interface{} `yaml:",inline"`
+}
+
+// RancherPortRule is a port rule of a load balancer.
+type RancherPortRule struct {
+	Hostname   string                 `yaml:"hostname,omitempty"`
+	Path       string                 `yaml:"path,omitempty"`
+	Service    string                 `yaml:"service,omitempty"`
+	Selector   string                 `yaml:"selector,omitempty"`
+	Protocol   string                 `yaml:"protocol,omitempty"`
+	SourcePort *RancherValue          `yaml:"source_port,omitempty"`
+	TargetPort *RancherValue          `yaml:"target_port,omitempty"`
+	Priority   *RancherValue          `yaml:"priority,omitempty"`
+	Extra      map[string]interface{} `yaml:",inline"`
+}
+
+// RancherValue is a number or a boolean of rancher-compose. It is kept as
+// written, since it may be a variable, and read with intValue and boolValue.
+type RancherValue struct {
+	raw interface{}
+}
+
+func (v *RancherValue) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	return unmarshal(&v.raw)
+}
+
+func (v RancherValue) MarshalYAML() (interface{}, error) {
+	return v.raw, nil
+}
+
+// LoadRancherCompose reads a rancher-compose file. The file is optional, an
+// empty one is returned when it does not exist.
+func (c *Converter) LoadRancherCompose(composeFile string) (rancherCompose *RancherCompose, err error) {
+	defer recoverError(&err)
+	rancherCompose = &RancherCompose{path: composeFile, answers: c.options.Answers}
+	file, err := ioutil.ReadFile(composeFile)
+	if os.IsNotExist(err) {
+		return rancherCompose, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s: %v", composeFile, err)
+	}
+	rancherCompose.data = file
+	if c.options.Answers != nil {
+		if file, err = answerFile(file, c.options.Answers); err != nil {
+			return nil, fmt.Errorf("Failed to parse %s: %v", composeFile, err)
+		}
+	}
+	if err := yaml.Unmarshal(file, rancherCompose); err != nil {
+		return nil, fmt.Errorf("Failed to parse %s: %v", composeFile, err)
+	}
+	rancherCompose.nested = rancherCompose.Version != ""
+	rancherCompose.catalogKey = ".catalog"
+	if rancherCompose.nested && rancherCompose.Catalog == nil {
+		// Version 2 files may name the section catalog.
+		var v2 struct {
+			Catalog *RancherCatalog `yaml:"catalog"`
+		}
+		if err := yaml.Unmarshal(file, &v2); err != nil {
+			return nil, fmt.Errorf("Failed to parse %s: %v", composeFile, err)
+		}
+		if v2.Catalog != nil {
+			rancherCompose.Catalog = v2.Catalog
+			rancherCompose.catalogKey = "catalog"
+		}
+	}
+	if !rancherCompose.nested {
+		// Version 1 files list the services at the top level.
+		rancherCompose.Services = nil
+		if err := yaml.Unmarshal(file, &rancherCompose.Services); err != nil {
+			return nil, fmt.Errorf("Failed to parse %s: %v", composeFile, err)
+		}
+		delete(rancherCompose.Services, ".catalog")
+	}
+	return rancherCompose, nil
+}
+
+// Loaded reports if the file was found.
+func (f *RancherCompose) Loaded() bool {
+	return f.data != nil
+}
+
+// Path returns the path the file was read from.
+func (f *RancherCompose) Path() string {
+	return f.path
+}
+
+// Marshal encodes the file in the layout it was written in.
+func (f *RancherCompose) Marshal() ([]byte, error) {
+	if f.nested {
+		file := yaml.MapSlice{{Key: "version", Value: f.Version}}
+		if f.Catalog != nil {
+			file = append(file, yaml.MapItem{Key: f.catalogKey, Value: f.Catalog})
+		}
+		if len(f.Services) > 0 {
+			file = append(file, yaml.MapItem{Key: "services", Value: f.Services})
+		}
+		return yaml.Marshal(file)
+	}
+	services := map[string]interface{}{}
+	if f.Catalog != nil {
+		services[".catalog"] = f.Catalog
+	}
+	for name, service := range f.Services {
+		services[name] = service
+	}
+	return yaml.Marshal(services)
+}
+
+// service returns the rancher-compose entry of a service, nil if it has none.
+func (f *RancherCompose) service(name string) *RancherService {
+	return f.Services[name]
+}
+
+// servicePath returns the path of a key of the entry of a service.
+func (f *RancherCompose) servicePath(name string, keys ...string) []string {
+	path := []string{name}
+	if f.nested {
+		path = []string{"services", name}
+	}
+	return append(path, keys...)
+}
+
+// fatalf stops on an error of the key at path, pointing to its line.
+func (f *RancherCompose) fatalf(path []string, format string, args ...interface{}) {
+	location := f.path
+	if line := keyLine(f.data, path); line > 0 {
+		location = fmt.Sprintf("%s:%d", f.path, line)
+	}
+	panic(conversionError{&Error{Key: strings.Join(path, "."), Message: fmt.Sprintf(format, args...), Location: location}})
+}
+
+// interpolate replaces the variables of a value with their answer, the
+// default of their question, the environment or their own default.
+func (f *RancherCompose) interpolate(path []string, value string) string {
+	return ComposeVariableRegexp.ReplaceAllStringFunc(value, func(reference string) string {
+		variable := ParseComposeVariable(reference)
+		value, ok := f.lookupVariable(variable.Name)
+		if value, ok = variable.Resolve(value, ok); !ok {
+			f.fatalf(path, "variable %s has no answer, default or environment value", variable.Name)
+		}
+		return value
+	})
+}
+
+func (f *RancherCompose) lookupVariable(name string) (string, bool) {
+	if answer, ok := f.answers[name]; ok {
+		return answer, true
+	}
+	for _, question := range f.Questions() {
+		if question.Variable == name {
+			return fmt.Sprint(question.DefaultValue()), true
+		}
+	}
+	return os.LookupEnv(name)
+}
+
+// intValue reads a number, defaultValue when it is missing.
+func (f *RancherCompose) intValue(value *RancherValue, defaultValue int32, path ...string) int32 {
+	if value == nil || value.raw == nil {
+		return defaultValue
+	}
+	switch raw := value.raw.(type) {
+	case int:
+		return int32(raw)
+	case string:
+		if number, err := strconv.ParseInt(strings.TrimSpace(f.interpolate(path, raw)), 10, 32); err == nil {
+			return int32(number)
+		}
+	}
+	f.fatalf(path, "expected a number, got %v", value.raw)
+	return 0
+}
+
+// boolValue reads a boolean, false when it is missing.
+func (f *RancherCompose) boolValue(value *RancherValue, path ...string) bool {
+	if value == nil || value.raw == nil {
+		return false
+	}
+	switch raw := value.raw.(type) {
+	case bool:
+		return raw
+	case string:
+		if b, err := strconv.ParseBool(strings.TrimSpace(f.interpolate(path, raw))); err == nil {
+			return b
+		}
+	}
+	f.fatalf(path, "expected true or false, got %v", value.raw)
+	return false
+}
+
+// keyLine returns the line of the key at path in a YAML file, or of its
+// deepest parent found, 0 when none is. List items are addressed as
+// key[index].
+func keyLine(data []byte, path []string) int {
+	lines := strings.Split(string(data), "\n")
+	line := 0
+	start, parent, item := 0, -1, false
+	for _, key := range path {
+		index := -1
+		if open := strings.Index(key, "["); open >= 0 {
+			index, _ = strconv.Atoi(strings.TrimSuffix(key[open+1:], "]"))
+			key = key[:open]
+		}
+		n := findKey(lines, start, parent, item, key)
+		if n < 0 {
+			return line
+		}
+		line = n + 1
+		parent, _, _ = yamlIndent(lines[n])
+		start, item = n+1, false
+		if index < 0 {
+			continue
+		}
+		n = findItem(lines, start, parent, index)
+		if n < 0 {
+			return line
+		}
+		line = n + 1
+		parent, _, _ = yamlIndent(lines[n])
+		parent -= 2
+		start, item = n, true
+	}
+	return line
+}
+
+// yamlIndent returns the indentation of the key of a line, counting the
+// dash of list items, the rest of the line and whether it is a list item.
+func yamlIndent(line string) (int, string, bool) {
+	text := strings.TrimLeft(line, " ")
+	indent := len(line) - len(text)
+	if strings.HasPrefix(text, "- ") {
+		return indent + 2, strings.TrimLeft(text[2:], " "), true
+	}
+	return indent, text, false
+}
+
+// findKey returns the line of a key among the children of the block that
+// starts at start, -1 if it is not there.
+func findKey(lines []string, start int, parent int, item bool, key string) int {
+	child := -1
+	for n := start; n < len(lines); n++ {
+		indent, text, dash := yamlIndent(lines[n])
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		raw := indent
+		if dash {
+			raw -= 2
+		}
+		if raw <= parent && !(item && n == start) {
+			return -1
+		}
+		if child < 0 {
+			child = indent
+		}
+		if indent != child {
+			continue
+		}
+		if colon := strings.Index(text, ":"); colon > 0 && strings.Trim(text[:colon], `"' `) == key {
+			return n
+		}
+	}
+	return -1
+}
+
+// findItem returns the line of an item of the list that starts at start,
+// -1 if it is not there.
+func findItem(lines []string, start int, parent int, index int) int {
+	count, items := 0, -1
+	for n := start; n < len(lines); n++ {
+		indent, text, dash := yamlIndent(lines[n])
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		raw := indent
+		if dash {
+			raw -= 2
+		}
+		if raw < parent || (raw == parent && !dash) {
+			return -1
+		}
+		if dash && (items < 0 || raw == items) {
+			items = raw
+			if count == index {
+				return n
+			}
+			count++
+		}
+	}
+	return -1
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/docker/libcompose/config"
+	"github.com/docker/libcompose/project"
+	"github.com/docker/libcompose/yaml"
+)
+
+// Statuses of the compose keys in a Report.
+const (
+	Mapped       = "mapped"
+	Approximated = "approximated"
+	Dropped      = "dropped"
+)
+
+// KeyReport tells what became of a compose key of a service. Target is the
+// Kubernetes field it is mapped to, Note explains an approximation or why
+// the key is dropped.
+type KeyReport struct {
+	Key    string `json:"key"`
+	Status string `json:"status"`
+	Target string `json:"target,omitempty"`
+	Note   string `json:"note,omitempty"`
+}
+
+// ServiceReport lists the keys set by a compose service.
+type ServiceReport struct {
+	Service string      `json:"service"`
+	Keys    []KeyReport `json:"keys"`
+}
+
+// Report tells, for every service of a project, which of its compose keys
+// are mapped to Kubernetes, approximated or dropped.
+type Report []ServiceReport
+
+// Dropped returns the dropped keys of every service.
+func (r Report) Dropped() Errors {
+	var dropped Errors
+	for _, service := range r {
+		for _, key := range service.Keys {
+			if key.Status == Dropped {
+				dropped = append(dropped, &Error{Service: service.Service, Key: key.Key, Message: "dropped, " + key.Note})
+			}
+		}
+	}
+	return dropped
+}
+
+// composeKeys tells what becomes of the keys of a service running pods.
+// Targets are relative to the pod template of the controller.
+var composeKeys = map[string]KeyReport{
+	"build":          {Status: Dropped, Note: "build and push the image, then set image"},
+	"cap_add":        {Status: Dropped, Note: "set securityContext.capabilities by hand"},
+	"cap_drop":       {Status: Dropped, Note: "set securityContext.capabilities by hand"},
+	"cgroup_parent":  {Status: Dropped, Note: "the kubelet manages the cgroups"},
+	"command":        {Status: Approximated, Target: "containers[].command", Note: "it replaces the entrypoint of the image too"},
+	"container_name": {Status: Dropped, Note: "pods are named after their controller"},
+	"cpu_quota":      {Status: Dropped, Note: "set resources.limits.cpu by hand"},
+	"cpu_shares":     {Status: Dropped, Note: "set resources.requests.cpu by hand"},
+	"cpuset":         {Status: Dropped, Note: "Kubernetes does not pin CPUs"},
+	"depends_on":     {Status: Dropped, Note: "Kubernetes has no start order"},
+	"devices":        {Status: Dropped, Note: "Kubernetes does not map host devices"},
+	"dns":            {Status: Dropped, Note: "pods use the cluster DNS"},
+	"dns_search":     {Status: Dropped, Note: "pods use the cluster DNS"},
+	"domainname":     {Status: Dropped, Note: "pods use the cluster domain"},
+	"entrypoint":     {Status: Dropped, Note: "command sets the entrypoint"},
+	"env_file":       {Status: Dropped, Note: "move the variables to environment"},
+	"environment":    {Status: Mapped, Target: "containers[].env"},
+	"expose":         {Status: Dropped, Note: "list the ports in ports"},
+	"extends":        {Status: Mapped, Target: "the keys of the extended service"},
+	"external_links": {Status: Dropped, Note: "reach the container through a Service"},
+	"extra_hosts":    {Status: Dropped, Note: "not supported by the targeted pod spec"},
+	"healthcheck":    {Status: Mapped, Target: "containers[].livenessProbe, containers[].readinessProbe"},
+	"hostname":       {Status: Dropped, Note: "pods are named after their controller"},
+	"image":          {Status: Mapped, Target: "containers[].image"},
+	"ipc":            {Status: Dropped, Note: "set hostIPC by hand"},
+	"labels":         {Status: Mapped, Target: "metadata.labels", Note: "Rancher labels configure the controller, scheduling and sidekicks"},
+	"links":          {Status: Approximated, Target: "Service", Note: "services reach each other through their Service name, aliases are left out"},
+	"logging":        {Status: Dropped, Note: "the cluster collects the container logs"},
+	"mac_address":    {Status: Dropped, Note: "pods get their addresses from the cluster network"},
+	"mem_limit":      {Status: Dropped, Note: "set resources.limits.memory by hand"},
+	"memswap_limit":  {Status: Dropped, Note: "Kubernetes does not limit swap"},
+	"network_mode":   {Status: Dropped, Note: "pods use the cluster network"},
+	"networks":       {Status: Dropped, Note: "pods use the cluster network"},
+	"pid":            {Status: Dropped, Note: "set hostPID by hand"},
+	"ports":          {Status: Mapped, Target: "containers[].ports, Service spec.ports"},
+	"privileged":     {Status: Dropped, Note: "set securityContext.privileged by hand"},
+	"read_only":      {Status: Dropped, Note: "set securityContext.readOnlyRootFilesystem by hand"},
+	"restart":        {Status: Mapped, Target: "restartPolicy"},
+	"security_opt":   {Status: Dropped, Note: "set the securityContext by hand"},
+	"shm_size":       {Status: Dropped, Note: "mount a memory emptyDir on /dev/shm"},
+	"stdin_open":     {Status: Dropped, Note: "set containers[].stdin by hand"},
+	"stop_signal":    {Status: Dropped, Note: "Kubernetes always stops containers with SIGTERM"},
+	"tty":            {Status: Dropped, Note: "set containers[].tty by hand"},
+	"ulimits":        {Status: Dropped, Note: "Kubernetes does not set ulimits"},
+	"user":           {Status: Dropped, Note: "set securityContext.runAsUser by hand"},
+	"uts":            {Status: Dropped, Note: "Kubernetes does not share the UTS namespace of the host"},
+	"volume_driver":  {Status: Dropped, Note: "use a PersistentVolumeClaim"},
+	"volumes":        {Status: Mapped, Target: "containers[].volumeMounts, volumes[].hostPath"},
+	"volumes_from":   {Status: Approximated, Target: "containers[].volumeMounts", Note: "only the volumes of sidekicks in the same pod are shared"},
+	"working_dir":    {Status: Dropped, Note: "set containers[].workingDir by hand"},
+	"x-kubernetes":   {Status: Mapped, Target: "the controller, Service and pod template it patches"},
+}
+
+// composeKeysOf returns the keys set by a service.
+func composeKeysOf(service *config.ServiceConfig) []string {
+	var keys []string
+	value := reflect.ValueOf(service).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		if field.IsZero() || field.Kind() == reflect.Slice && field.Len() == 0 || field.Kind() == reflect.Map && field.Len() == 0 {
+			continue
+		}
+		key := strings.Split(value.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if key == "networks" && onlyDefaultNetwork(service.Networks) {
+			// The parser puts the services of version 2 files on the
+			// default network.
+			continue
+		}
+		if key != "" && key != "-" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// reportService tells what becomes of the keys of a service.
+func (c *conversion) reportService(name string, service *config.ServiceConfig) ServiceReport {
+	keys := composeKeysOf(service)
+	if _, ok := c.healthChecks[name]; ok {
+		keys = append(keys, "healthcheck")
+	}
+	if c.extensions.services[name] != nil {
+		keys = append(keys, extensionKey)
+	}
+	sort.Strings(keys)
+	report := ServiceReport{Service: name}
+	for _, key := range keys {
+		entry, ok := composeKeys[key]
+		if !ok {
+			entry = KeyReport{Status: Dropped, Note: "unknown key"}
+		}
+		entry.Key = key
+		switch {
+		case key == "ports" && hasHostPorts(service):
+			entry.Status, entry.Note = Approximated, "host ports are left out, the Service exposes the container ports"
+		case key == "environment" && hasUnsetVariables(service):
+			entry.Status, entry.Note = Approximated, "variables without a value are left out"
+		case key == "healthcheck" && c.healthChecks[name].Disable:
+			entry.Target = "no probe"
+		case key == "healthcheck" && c.options.HealthCheckProbes == "liveness":
+			entry.Target = "containers[].livenessProbe"
+		case key == "healthcheck" && c.options.HealthCheckProbes == "readiness":
+			entry.Target = "containers[].readinessProbe"
+		}
+		switch {
+		case isLoadBalancer(service) && key == "image":
+			entry = KeyReport{Key: key, Status: Approximated, Target: "Ingress, Service", Note: "the Rancher load balancer becomes an Ingress or LoadBalancer Services"}
+		case isLoadBalancer(service) && key == "ports":
+			entry = KeyReport{Key: key, Status: Mapped, Target: "Ingress, Service spec.ports"}
+		case isExternalService(service) && key == "image":
+			entry = KeyReport{Key: key, Status: Approximated, Target: "Service, Endpoints", Note: "the Rancher external service becomes a Service"}
+		case isDNSService(service) && key == "image":
+			entry = KeyReport{Key: key, Status: Approximated, Target: "Service", Note: "the Rancher DNS alias becomes a Service"}
+		case isDNSService(service) && key == "links":
+			entry = KeyReport{Key: key, Status: Mapped, Target: "Service spec.selector"}
+		}
+		report.Keys = append(report.Keys, entry)
+	}
+	return report
+}
+
+// reportProject tells what becomes of the keys of every service. With
+// Strict the dropped keys are errors of their service.
+func (c *conversion) reportProject(dockerCompose *project.Project) Report {
+	var report Report
+	names := dockerCompose.ServiceConfigs.Keys()
+	sort.Strings(names)
+	for _, name := range names {
+		service, _ := dockerCompose.ServiceConfigs.Get(name)
+		report = append(report, c.reportService(name, service))
+	}
+	if c.options.Strict {
+		for _, err := range report.Dropped() {
+			c.addError(err)
+		}
+	}
+	return report
+}
+
+func onlyDefaultNetwork(networks *yaml.Networks) bool {
+	return len(networks.Networks) == 1 && networks.Networks[0].Name == "default" && len(networks.Networks[0].Aliases) == 0
+}
+
+func hasHostPorts(service *config.ServiceConfig) bool {
+	for _, port := range service.Ports {
+		if strings.Contains(port, ":") {
+			return true
+		}
+	}
+	return false
+}
+
+func hasUnsetVariables(service *config.ServiceConfig) bool {
+	for _, env := range service.Environment {
+		if !strings.Contains(env, "=") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ghodss/yaml"
+)
+
+// Transformer changes the objects of a conversion before they are written.
+// Transformers work on the Versioned objects, the ones written out. The
+// warnings returned along with the objects, problems that do not stop the
+// conversion, are added to the warnings of the result.
+type Transformer interface {
+	Transform(objects []Object) (transformed []Object, warnings []string, err error)
+}
+
+// TransformerFunc turns a function into a Transformer.
+type TransformerFunc func(objects []Object) ([]Object, []string, error)
+
+// Transform calls f.
+func (f TransformerFunc) Transform(objects []Object) ([]Object, []string, error) {
+	return f(objects)
+}
+
+// TransformerFactory builds a transformer from its argument, the text
+// after the = of the -transform flag.
+type TransformerFactory func(arg string) (Transformer, error)
+
+var (
+	transformersMutex sync.Mutex
+	transformers      = map[string]TransformerFactory{}
+)
+
+func init() {
+	RegisterTransformer("labels", newLabelsTransformer)
+	RegisterTransformer("registry", newRegistryTransformer)
+	RegisterTransformer("sidecar", newSidecarTransformer)
+}
+
+// RegisterTransformer makes a transformer available by name to
+// NewTransformer, and so to the -transform flag of the tools built with it.
+func RegisterTransformer(name string, factory TransformerFactory) {
+	transformersMutex.Lock()
+	defer transformersMutex.Unlock()
+	transformers[name] = factory
+}
+
+// Transformers returns the names of the registered transformers.
+func Transformers() []string {
+	transformersMutex.Lock()
+	defer transformersMutex.Unlock()
+	var names []string
+	for name := range transformers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewTransformer builds the registered transformer called name.
+func NewTransformer(name string, arg string) (Transformer, error) {
+	transformersMutex.Lock()
+	factory, ok := transformers[name]
+	transformersMutex.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("Unknown transformer %s, expected one of %s", name, strings.Join(Transformers(), ", "))
+	}
+	transformer, err := factory(arg)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s transformer: %v", name, err)
+	}
+	return transformer, nil
+}
+
+// transform runs the objects through the transformers, in order.
+func (c *conversion) transform(objects []Object, transformers []Transformer) ([]Object, error) {
+	for _, transformer := range transformers {
+		transformed, warnings, err := transformer.Transform(objects)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to transform the objects: %v", err)
+		}
+		c.warnings = append(c.warnings, warnings...)
+		objects = transformed
+	}
+	return objects, nil
+}
+
+// newLabelsTransformer adds the comma separated key=value labels to every
+// object and pod template. Selectors are left alone.
+func newLabelsTransformer(arg string) (Transformer, error) {
+	labels := map[string]interface{}{}
+	for _, label := range strings.Split(arg, ",") {
+		parts := strings.SplitN(strings.TrimSpace(label), "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid label %s, expected key=value", label)
+		}
+		labels[parts[0]] = parts[1]
+	}
+	addLabels := func(meta interface{}) {
+		metadata, ok := meta.(map[string]interface{})
+		if !ok {
+			return
+		}
+		existing, _ := metadata["labels"].(map[string]interface{})
+		if existing == nil {
+			existing = map[string]interface{}{}
+			metadata["labels"] = existing
+		}
+		for key, value := range labels {
+			existing[key] = value
+		}
+	}
+	return TransformerFunc(func(objects []Object) ([]Object, []string, error) {
+		for _, object := range objects {
+			addLabels(object.Versioned["metadata"])
+			if template := PodTemplateOf(object.Versioned); template != nil {
+				addLabels(template["metadata"])
+			}
+		}
+		return objects, nil, nil
+	}), nil
+}
+
+// newRegistryTransformer pulls the images that name no registry from the
+// registry given as argument.
+func newRegistryTransformer(arg string) (Transformer, error) {
+	registry := strings.TrimSuffix(arg, "/")
+	if registry == "" {
+		return nil, fmt.Errorf("missing registry")
+	}
+	return TransformerFunc(func(objects []Object) ([]Object, []string, error) {
+		for _, object := range objects {
+			for _, container := range podContainers(object.Versioned) {
+				image, ok := container["image"].(string)
+				if ok && image != "" && !hasRegistry(image) {
+					container["image"] = registry + "/" + image
+				}
+			}
+		}
+		return objects, nil, nil
+	}), nil
+}
+
+// hasRegistry reports if the first component of an image names a registry
+// host, the way Docker tells them apart.
+func hasRegistry(image string) bool {
+	slash := strings.Index(image, "/")
+	if slash == -1 {
+		return false
+	}
+	host := image[:slash]
+	return strings.ContainsAny(host, ".:") || host == "localhost"
+}
+
+// newSidecarTransformer adds the containers of the YAML file given as
+// argument, a container or a list of them, to every pod template.
+func newSidecarTransformer(arg string) (Transformer, error) {
+	data, err := ioutil.ReadFile(arg)
+	if err != nil {
+		return nil, err
+	}
+	var sidecars []interface{}
+	if err := yaml.Unmarshal(data, &sidecars); err != nil {
+		var sidecar map[string]interface{}
+		if err := yaml.Unmarshal(data, &sidecar); err != nil {
+			return nil, fmt.Errorf("%s is neither a container nor a list of containers: %v", arg, err)
+		}
+		sidecars = []interface{}{sidecar}
+	}
+	for _, sidecar := range sidecars {
+		if container, ok := sidecar.(map[string]interface{}); !ok || container["name"] == nil || container["image"] == nil {
+			return nil, fmt.Errorf("the containers of %s need a name and an image", arg)
+		}
+	}
+	return TransformerFunc(func(objects []Object) ([]Object, []string, error) {
+		for _, object := range objects {
+			podSpec := PodSpecOf(object.Versioned)
+			if podSpec == nil {
+				continue
+			}
+			containers, _ := podSpec["containers"].([]interface{})
+			for _, sidecar := range sidecars {
+				// Every pod gets its own copy to transform further.
+				data, _ := yaml.Marshal(sidecar)
+				var container interface{}
+				yaml.Unmarshal(data, &container)
+				containers = append(containers, container)
+			}
+			podSpec["containers"] = containers
+		}
+		return objects, nil, nil
+	}), nil
+}
+
+// podContainers returns the containers and init containers of the pod spec
+// of a versioned controller.
+func podContainers(object map[string]interface{}) []map[string]interface{} {
+	podSpec := PodSpecOf(object)
+	var containers []map[string]interface{}
+	for _, key := range []string{"containers", "initContainers"} {
+		items, _ := podSpec[key].([]interface{})
+		for _, item := range items {
+			if container, ok := item.(map[string]interface{}); ok {
+				containers = append(containers, container)
+			}
+		}
+	}
+	return containers
+}
@@ -13,24 +13,160 @@ limitations under the License.
 
 package main
 
-import "flag"
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/kelseyhightower/compose2kube/converter"
+)
 
 var (
 	composeFilePath string
 	outputDir       string
 	asJSON          bool
+	outputFormat    string
+	kubeVersion     string
+
+	healthCheckProbes string
+	answersFile       string
+	promptAnswers     bool
+
+	skipFailingServices bool
+	strict              bool
+	reportFormat        string
+	reportFile          string
+	transforms          listFlag
+	patchFiles          listFlag
+	functionsFile       string
+	checkMode           bool
+	prune               bool
+	merge               bool
+	allowConflicts      bool
 )
 
+// listFlag collects the values of a repeated flag.
+type listFlag []string
+
+func (f *listFlag) String() string {
+	return strings.Join(*f, " ")
+}
+
+func (f *listFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// conversionErrorsExitCode is the exit code when services of the project
+// cannot be converted, other failures exit with 1.
+const conversionErrorsExitCode = 2
+
+// servicesLeftOut tells whether -skip-failing-services left services out.
+var servicesLeftOut bool
+
 func init() {
 	flag.StringVar(&composeFilePath, "compose-file-path", "./", "Specify an alternate path for compose files")
 	flag.StringVar(&outputDir, "output-dir", "output", "Kubernetes configs output `directory`")
 	flag.BoolVar(&asJSON, "json", false, "output json instead of yaml")
+	flag.StringVar(&outputFormat, "format", "yaml", "output `format`: yaml, json, helm, kustomize, openshift or catalog")
+	flag.StringVar(&kubeVersion, "kube-version", "1.1", "Kubernetes `version` the generated objects target")
+	flag.StringVar(&answersFile, "answers", "", "YAML or JSON `file` answering the catalog questions")
+	flag.BoolVar(&promptAnswers, "prompt", false, "prompt for the catalog questions the answers file leaves out")
+	flag.StringVar(&healthCheckProbes, "healthcheck-probes", "both", "probes made from compose healthchecks: liveness, readiness or both")
+	flag.BoolVar(&skipFailingServices, "skip-failing-services", false, "leave out the services with errors and convert the others")
+	flag.BoolVar(&strict, "strict", false, "fail when compose keys are dropped by the conversion")
+	flag.StringVar(&reportFormat, "report", "", "write the report of the converted compose keys in `format`: text, json or markdown")
+	flag.StringVar(&reportFile, "report-file", "", "write the report to `file` instead of the standard output")
+	flag.BoolVar(&checkMode, "check", false, "compare the output directory with the files the compose files convert to instead of writing them, exit with 3 when they differ")
+	flag.BoolVar(&prune, "prune", true, "remove the files of the output directory written by a previous run and no longer generated")
+	flag.BoolVar(&merge, "merge", true, "keep the edits made to the files of the output directory since the previous run, with a three-way merge")
+	flag.BoolVar(&allowConflicts, "allow-conflicts", false, "with -merge, keep the edited values the conversion changed too instead of failing")
+	flag.Var(&patchFiles, "patch", "apply the patches of the YAML `file` to the objects after the transformers and functions, may be repeated")
+	flag.StringVar(&functionsFile, "functions", "", "YAML `file` listing the KRM function commands run over the objects after the transformers")
+	flag.Var(&transforms, "transform", "run the `name[=arg]` transformer over the objects before writing them, may be repeated: "+strings.Join(converter.Transformers(), ", "))
 }
 
 func main() {
 	flag.Parse()
-	dockerCompose := parseDockerCompose()
-	rancherCompose := parseRancherCompose()
-	processDockerCompose(dockerCompose, rancherCompose)
-	processRancherCompose(rancherCompose)
+	if asJSON {
+		outputFormat = "json"
+	}
+	options := converter.Options{
+		KubeVersion:         kubeVersion,
+		HealthCheckProbes:   healthCheckProbes,
+		SkipFailingServices: skipFailingServices,
+		Strict:              strict,
+	}
+	for _, transform := range transforms {
+		parts := strings.SplitN(transform, "=", 2)
+		transformer, err := converter.NewTransformer(parts[0], strings.Join(parts[1:], ""))
+		if err != nil {
+			log.Fatal(err)
+		}
+		options.Transformers = append(options.Transformers, transformer)
+	}
+	if functionsFile != "" {
+		functions, err := converter.LoadFunctions(functionsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		options.Transformers = append(options.Transformers, functions...)
+	}
+	for _, patchFile := range patchFiles {
+		patches, err := converter.LoadPatches(patchFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		options.Transformers = append(options.Transformers, patches)
+	}
+	var err error
+	if conv, err = converter.New(options); err != nil {
+		log.Fatal(err)
+	}
+	if answersFile != "" || promptAnswers {
+		answers = loadAnswers(parseRancherCompose(), answersFile, promptAnswers)
+		options.Answers = answers
+		if conv, err = converter.New(options); err != nil {
+			log.Fatal(err)
+		}
+	}
+	switch outputFormat {
+	case "yaml", "json":
+		p := parseDockerCompose()
+		processDockerCompose(outputDir, convertProject(p))
+		processRancherCompose(outputDir, p)
+	case "helm":
+		p := parseDockerCompose()
+		processDockerCompose(outputDir, convertProject(p))
+		writeHelmChart(p.Rancher)
+	case "catalog":
+		if checkMode {
+			log.Fatal("-check does not support the catalog format, every conversion adds a version folder")
+		}
+		writeCatalogTemplate(parseDockerCompose())
+	case "openshift":
+		writeOpenShiftTemplate(parseDockerCompose())
+	case "kustomize":
+		writeKustomize(composeFilePath + "docker-compose.yml")
+	default:
+		log.Fatalf("Unknown output format %s", outputFormat)
+	}
+	// Like the other conversion errors, a strict failure writes nothing.
+	if strict && servicesLeftOut {
+		log.Printf("Failed to convert the project, -strict does not allow leaving services out")
+		os.Exit(conversionErrorsExitCode)
+	}
+	if checkMode {
+		checkOutput()
+	} else {
+		writeOutput()
+		// The catalog keeps the files of its previous versions.
+		if outputFormat != "catalog" {
+			if prune {
+				pruneOutput()
+			}
+			writeManifest()
+		}
+	}
 }
@@ -0,0 +1,497 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/kelseyhightower/compose2kube/converter"
+)
+
+// checkDriftExitCode is the exit code of -check when the output directory
+// differs from the generated files.
+const checkDriftExitCode = 3
+
+// manifestName is the file of the output directory listing the files
+// compose2kube wrote there. It has no YAML extension for kubectl to skip it.
+const manifestName = ".compose2kube-manifest"
+
+// baseName is the file of the output directory keeping, with -merge, the
+// generated content of the files, the base of the merge of their edits. It
+// has no YAML extension either.
+const baseName = ".compose2kube-base"
+
+// manifest maps the generated files, relative to the output directory, to
+// the digest of their generated content, which tells whether the file on
+// disk was edited since.
+type manifest struct {
+	Files map[string]string `json:"files"`
+}
+
+// renderedFile is a generated file, data being the content written: the
+// generated one or, with -merge, the one merged with the edits of the file
+// on disk and its conflicts.
+type renderedFile struct {
+	path      string
+	data      []byte
+	generated []byte
+	conflicts []string
+}
+
+// rendered holds the generated files, in order.
+var rendered []renderedFile
+
+// previousManifest and previousBase cache the manifest and the base of the
+// previous run.
+var (
+	previousManifest *manifest
+	previousBase     map[string]string
+)
+
+// outputFile records a generated file, written by writeOutput or compared
+// with the one on disk by checkOutput. With -merge the edits made to the
+// file since the previous run are merged.
+func outputFile(path string, data []byte) {
+	file := renderedFile{path: path, data: data, generated: data}
+	if merge {
+		file.data, file.conflicts = mergeEdits(path, data)
+	}
+	rendered = append(rendered, file)
+}
+
+// writeOutput writes the generated files and prints their paths. Merge
+// conflicts stop the run before any file is written, unless
+// -allow-conflicts keeps the edited values.
+func writeOutput() {
+	conflicts := 0
+	for _, file := range rendered {
+		for _, conflict := range file.conflicts {
+			if allowConflicts {
+				log.Printf("Warning: %s: %s", file.path, conflict)
+			} else {
+				log.Printf("Error: %s: %s", file.path, conflict)
+			}
+		}
+		conflicts += len(file.conflicts)
+	}
+	if conflicts > 0 && !allowConflicts {
+		log.Fatalf("Failed to merge the edits of %s, resolve the conflicts above or keep the edited values with -allow-conflicts", outputDir)
+	}
+	for _, file := range rendered {
+		if err := os.MkdirAll(filepath.Dir(file.path), 0755); err != nil {
+			log.Fatalf("Failed to create the directory %s: %v", filepath.Dir(file.path), err)
+		}
+		if err := ioutil.WriteFile(file.path, file.data, 0644); err != nil {
+			log.Fatalf("Failed to write file %s: %v", file.path, err)
+		}
+		fmt.Println(file.path)
+	}
+}
+
+func digest(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
+// readManifest returns the manifest of the output directory, an empty one
+// when it has none. Paths leaving the directory are ignored.
+func readManifest() *manifest {
+	if previousManifest != nil {
+		return previousManifest
+	}
+	previousManifest = &manifest{Files: map[string]string{}}
+	var m manifest
+	if !readOutputFile(manifestName, &m) {
+		return previousManifest
+	}
+	for file, sum := range m.Files {
+		file = filepath.Clean(file)
+		if filepath.IsAbs(file) || file == ".." || strings.HasPrefix(file, ".."+string(filepath.Separator)) {
+			log.Printf("Warning: %s lists %s outside of %s, leaving it alone", manifestName, file, outputDir)
+			continue
+		}
+		previousManifest.Files[file] = sum
+	}
+	return previousManifest
+}
+
+// readBase returns the generated content of the files of the previous run,
+// keyed like the manifest.
+func readBase() map[string]string {
+	if previousBase == nil {
+		previousBase = map[string]string{}
+		readOutputFile(baseName, &previousBase)
+	}
+	return previousBase
+}
+
+// readOutputFile parses a YAML file of the output directory, it returns
+// false when there is no such file.
+func readOutputFile(name string, object interface{}) bool {
+	data, err := ioutil.ReadFile(filepath.Join(outputDir, name))
+	if os.IsNotExist(err) {
+		return false
+	}
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", filepath.Join(outputDir, name), err)
+	}
+	if err := yaml.Unmarshal(data, object); err != nil {
+		log.Fatalf("Failed to parse %s: %v", filepath.Join(outputDir, name), err)
+	}
+	return true
+}
+
+// relativePath returns the path of a generated file relative to the output
+// directory, as listed in the manifest.
+func relativePath(path string) string {
+	relative, err := filepath.Rel(outputDir, path)
+	if err != nil {
+		log.Fatalf("Failed to list %s in the manifest: %v", path, err)
+	}
+	return relative
+}
+
+// mergeEdits returns the content of a generated file keeping the edits
+// made to the file on disk since the previous run, and the conflicts of the
+// merge. Files whose digest is the one of the manifest were not edited. The
+// others get a three-way merge of the base, the previously generated file,
+// the one on disk and the generated one. Files without base, that do not
+// parse as YAML and Helm templates are merged as a whole: an edited file the
+// conversion changes is kept as a conflict.
+func mergeEdits(path string, generated []byte) ([]byte, []string) {
+	relative := relativePath(path)
+	sum, ok := readManifest().Files[relative]
+	if !ok {
+		return generated, nil
+	}
+	onDisk, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return generated, nil
+	}
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", path, err)
+	}
+	previous, hasBase := readBase()[relative]
+	switch {
+	case digest(onDisk) == sum, string(onDisk) == string(generated):
+		return generated, nil
+	case !hasBase:
+		return onDisk, []string{"the file was edited and has no base to merge the conversion with, kept the edited file"}
+	case string(onDisk) == previous:
+		return generated, nil
+	case string(generated) == previous:
+		return onDisk, nil
+	}
+	var previousObject, editedObject, generatedObject map[string]interface{}
+	if strings.Contains(previous, "{{") || strings.Contains(string(generated), "{{") ||
+		yaml.Unmarshal([]byte(previous), &previousObject) != nil ||
+		yaml.Unmarshal(onDisk, &editedObject) != nil ||
+		yaml.Unmarshal(generated, &generatedObject) != nil ||
+		editedObject == nil || generatedObject == nil {
+		return onDisk, []string{"the file was edited and the conversion changed it, kept the edited file"}
+	}
+	merged, conflicts := converter.ThreeWayMerge(previousObject, editedObject, generatedObject)
+	var messages []string
+	for _, conflict := range conflicts {
+		messages = append(messages, fmt.Sprintf("conflict at %s", conflict))
+	}
+	var data []byte
+	if filepath.Ext(path) == ".json" {
+		data, err = json.MarshalIndent(merged, "", "  ")
+	} else {
+		data, err = yaml.Marshal(merged)
+	}
+	if err != nil {
+		log.Fatalf("Failed to marshal the merged %s: %v", path, err)
+	}
+	return data, messages
+}
+
+// staleFiles returns the paths of the files of the manifest that are no
+// longer generated, sorted.
+func staleFiles() []string {
+	generated := map[string]bool{}
+	for _, file := range rendered {
+		generated[filepath.Clean(file.path)] = true
+	}
+	var stale []string
+	for file := range readManifest().Files {
+		path := filepath.Join(outputDir, file)
+		if !generated[path] {
+			stale = append(stale, path)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+// pruneOutput deletes the files compose2kube wrote on a previous run and no
+// longer generates, along with the directories they leave empty. Files it
+// did not write are left untouched.
+func pruneOutput() {
+	for _, path := range staleFiles() {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Fatalf("Failed to remove the stale file %s: %v", path, err)
+		}
+		log.Printf("Removed the stale file %s", path)
+		for dir := filepath.Dir(path); dir != filepath.Clean(outputDir) && dir != "."; dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+}
+
+// writeManifest lists the digests of the generated files in the manifest and,
+// with -merge, their generated content in the base. Without -prune the
+// stale files stay listed. Without -merge the base is removed, the files no
+// longer match it.
+func writeManifest() {
+	m := manifest{Files: map[string]string{}}
+	base := map[string]string{}
+	for _, file := range rendered {
+		relative := relativePath(file.path)
+		m.Files[relative] = digest(file.generated)
+		base[relative] = string(file.generated)
+	}
+	if !prune {
+		for _, path := range staleFiles() {
+			relative := relativePath(path)
+			m.Files[relative] = readManifest().Files[relative]
+			if generated, ok := readBase()[relative]; ok {
+				base[relative] = generated
+			}
+		}
+	}
+	writeOutputFile(manifestName, "# Files written by compose2kube and the digest of their generated content,\n# -prune removes the ones it no longer generates.\n", m)
+	if merge {
+		writeOutputFile(baseName, "# Files generated by compose2kube, -merge merges the edits made to them\n# with the next conversion.\n", base)
+	} else if err := os.Remove(filepath.Join(outputDir, baseName)); err != nil && !os.IsNotExist(err) {
+		log.Fatalf("Failed to remove %s: %v", filepath.Join(outputDir, baseName), err)
+	}
+}
+
+func writeOutputFile(name string, header string, object interface{}) {
+	data, err := yaml.Marshal(object)
+	if err != nil {
+		log.Fatalf("Failed to marshal %s: %v", name, err)
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		log.Fatalf("Failed to create the output directory %s: %v", outputDir, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(outputDir, name), append([]byte(header), data...), 0644); err != nil {
+		log.Fatalf("Failed to write file %s: %v", filepath.Join(outputDir, name), err)
+	}
+}
+
+// checkOutput compares the written files with the ones on disk, prints the
+// diff of every file that differs, is missing or would be pruned, and exits
+// with checkDriftExitCode if any does. YAML and JSON files are compared by
+// content, ignoring the order of the keys and the formatting. With -merge
+// the files with merge conflicts are out of date too, and diffed with the
+// generated file.
+func checkOutput() {
+	drift := 0
+	for _, file := range rendered {
+		expected := file.data
+		for _, conflict := range file.conflicts {
+			log.Printf("Error: %s: %s", file.path, conflict)
+			expected = file.generated
+		}
+		onDisk, err := ioutil.ReadFile(file.path)
+		if err != nil && !os.IsNotExist(err) {
+			log.Fatalf("Failed to read %s: %v", file.path, err)
+		}
+		if err != nil {
+			drift++
+			fmt.Print(unifiedDiff(nil, splitLines(expected), "/dev/null", file.path+" (generated)"))
+			continue
+		}
+		current, generated, same := compareFiles(onDisk, expected)
+		if same && len(file.conflicts) == 0 {
+			continue
+		}
+		drift++
+		if !same {
+			fmt.Print(unifiedDiff(splitLines(current), splitLines(generated), file.path+" (on disk)", file.path+" (generated)"))
+		}
+	}
+	if prune {
+		for _, path := range staleFiles() {
+			onDisk, err := ioutil.ReadFile(path)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				log.Fatalf("Failed to read %s: %v", path, err)
+			}
+			drift++
+			fmt.Print(unifiedDiff(splitLines(onDisk), nil, path+" (on disk)", "/dev/null"))
+		}
+	}
+	if drift > 0 {
+		files := "files"
+		if drift == 1 {
+			files = "file"
+		}
+		log.Printf("Out of date in %s: %d %s, convert the compose files again", outputDir, drift, files)
+		os.Exit(checkDriftExitCode)
+	}
+	log.Printf("The %d files in %s are up to date", len(rendered), outputDir)
+}
+
+// compareFiles compares a file on disk with the generated one. Files that
+// both parse as YAML are compared by content and returned in a canonical
+// form, the others and the Helm templates are compared as text.
+func compareFiles(onDisk []byte, generated []byte) ([]byte, []byte, bool) {
+	var current, expected interface{}
+	if !strings.Contains(string(generated), "{{") && yaml.Unmarshal(onDisk, &current) == nil && yaml.Unmarshal(generated, &expected) == nil && current != nil {
+		if reflect.DeepEqual(current, expected) {
+			return nil, nil, true
+		}
+		currentData, errCurrent := yaml.Marshal(current)
+		expectedData, errExpected := yaml.Marshal(expected)
+		if errCurrent == nil && errExpected == nil {
+			return currentData, expectedData, false
+		}
+	}
+	return onDisk, generated, string(onDisk) == string(generated)
+}
+
+// noNewlineMarker follows a last line missing its newline in a diff.
+const noNewlineMarker = "\\ No newline at end of file"
+
+// splitLines splits a text in lines for unifiedDiff. A last line missing its
+// newline carries the noNewlineMarker, so that it differs from the same line
+// with a newline.
+func splitLines(data []byte) []string {
+	text := string(data)
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if !strings.HasSuffix(text, "\n") {
+		lines[len(lines)-1] += "\n" + noNewlineMarker
+	}
+	return lines
+}
+
+// unifiedDiff returns the differences of two texts in the unified format,
+// with three lines of context.
+func unifiedDiff(a []string, b []string, fromName string, toName string) string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// The edit script, one entry per line: ' ', '-' or '+' and the indexes
+	// of the line in a and b.
+	type edit struct {
+		op   byte
+		i, j int
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', i, j})
+			i++
+		default:
+			edits = append(edits, edit{'+', i, j})
+			j++
+		}
+	}
+
+	const context = 3
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+	for start := 0; start < len(edits); {
+		if edits[start].op == ' ' {
+			start++
+			continue
+		}
+		// A hunk runs from the context before the change to the context
+		// after the last change less than two contexts away.
+		first := start - context
+		if first < 0 {
+			first = 0
+		}
+		end := start
+		for k := start; k < len(edits) && k <= end+2*context+1; k++ {
+			if edits[k].op != ' ' {
+				end = k
+			}
+		}
+		last := end + context
+		if last >= len(edits) {
+			last = len(edits) - 1
+		}
+		fromCount, toCount := 0, 0
+		for _, e := range edits[first : last+1] {
+			if e.op != '+' {
+				fromCount++
+			}
+			if e.op != '-' {
+				toCount++
+			}
+		}
+		fromStart, toStart := edits[first].i+1, edits[first].j+1
+		if fromCount == 0 {
+			fromStart--
+		}
+		if toCount == 0 {
+			toStart--
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", fromStart, fromCount, toStart, toCount)
+		for _, e := range edits[first : last+1] {
+			switch e.op {
+			case ' ':
+				fmt.Fprintf(&out, " %s\n", a[e.i])
+			case '-':
+				fmt.Fprintf(&out, "-%s\n", a[e.i])
+			case '+':
+				fmt.Fprintf(&out, "+%s\n", b[e.j])
+			}
+		}
+		start = last + 1
+	}
+	return out.String()
+}
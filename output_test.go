@@ -0,0 +1,117 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := func(n int) string {
+		var text string
+		for i := 1; i <= n; i++ {
+			text += string(rune('a'+i-1)) + "\n"
+		}
+		return text
+	}
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want []string
+	}{
+		{
+			name: "three lines of context around a change",
+			from: lines(10),
+			to:   strings.Replace(lines(10), "e\n", "E\n", 1),
+			want: []string{
+				"@@ -2,7 +2,7 @@",
+				" b", " c", " d", "-e", "+E", " f", " g", " h",
+			},
+		},
+		{
+			name: "context at the start and end of the file",
+			from: "a\nb\n",
+			to:   "A\nb\n",
+			want: []string{
+				"@@ -1,2 +1,2 @@",
+				"-a", "+A", " b",
+			},
+		},
+		{
+			name: "changes more than six lines apart get their own hunk",
+			from: lines(12),
+			to:   strings.Replace(strings.Replace(lines(12), "a\n", "A\n", 1), "l\n", "L\n", 1),
+			want: []string{
+				"@@ -1,4 +1,4 @@",
+				"-a", "+A", " b", " c", " d",
+				"@@ -9,4 +9,4 @@",
+				" i", " j", " k", "-l", "+L",
+			},
+		},
+		{
+			name: "changes six lines apart share a hunk",
+			from: lines(8),
+			to:   strings.Replace(strings.Replace(lines(8), "a\n", "A\n", 1), "h\n", "H\n", 1),
+			want: []string{
+				"@@ -1,8 +1,8 @@",
+				"-a", "+A", " b", " c", " d", " e", " f", " g", "-h", "+H",
+			},
+		},
+		{
+			name: "added lines",
+			from: "a\nc\n",
+			to:   "a\nb\nc\n",
+			want: []string{
+				"@@ -1,2 +1,3 @@",
+				" a", "+b", " c",
+			},
+		},
+		{
+			name: "added file",
+			from: "",
+			to:   "a\nb\n",
+			want: []string{
+				"@@ -0,0 +1,2 @@",
+				"+a", "+b",
+			},
+		},
+		{
+			name: "removed file",
+			from: "a\nb\n",
+			to:   "",
+			want: []string{
+				"@@ -1,2 +0,0 @@",
+				"-a", "-b",
+			},
+		},
+		{
+			name: "missing trailing newline",
+			from: "a\nb",
+			to:   "a\nb\n",
+			want: []string{
+				"@@ -1,2 +1,2 @@",
+				" a", "-b", `\ No newline at end of file`, "+b",
+			},
+		},
+	}
+	for _, test := range tests {
+		got := unifiedDiff(splitLines([]byte(test.from)), splitLines([]byte(test.to)), "from", "to")
+		want := "--- from\n+++ to\n" + strings.Join(test.want, "\n") + "\n"
+		if got != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, want)
+		}
+	}
+}
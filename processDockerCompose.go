@@ -16,90 +16,102 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 
-	"github.com/docker/libcompose/config"
-	"github.com/docker/libcompose/project"
-	"gopkg.in/yaml.v2"
+	"github.com/ghodss/yaml"
+	"github.com/kelseyhightower/compose2kube/converter"
 )
 
-func parseDockerCompose() *project.Project {
-	composeFile := composeFilePath + "docker-compose.yml"
-	p := project.NewProject(&project.Context{
-		ProjectName:  "kube",
-		ComposeFiles: []string{composeFile},
-	}, nil, &config.ParseOptions{})
+// conv converts the compose files with the options given by the flags.
+var conv *converter.Converter
 
-	if err := p.Parse(); err != nil {
-		log.Fatalf("Failed to parse the compose project from %s: %v", composeFile, err)
-	}
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		log.Fatalf("Failed to create the output directory %s: %v", outputDir, err)
-	}
+func parseDockerCompose() *converter.Project {
+	return parseComposeFiles(composeFilePath + "docker-compose.yml")
+}
 
-	if p.ServiceConfigs == nil {
-		log.Fatalf("No service config found, aborting")
+// parseComposeFiles parses a compose project and the rancher-compose.yml of
+// its stack, later files override the services of the earlier ones.
+func parseComposeFiles(composeFiles ...string) *converter.Project {
+	p, err := conv.LoadProject(composeFiles...)
+	if err != nil {
+		log.Fatal(err)
 	}
+	p.Rancher = parseRancherCompose()
 	return p
 }
 
-func writeFile(shortName string, sufix string, object interface{}) {
-	data, err := json.MarshalIndent(object, "", "  ")
+func parseRancherCompose() *converter.RancherCompose {
+	rancherCompose, err := conv.LoadRancherCompose(composeFilePath + "rancher-compose.yml")
 	if err != nil {
-		log.Fatalf("Failed to marshal file %s-%s: %v", shortName, sufix, err)
+		log.Fatal(err)
 	}
-	if asJSON {
-		// Save the replication controller for the Docker compose service to the
-		// configs directory.
-		outputFileName := fmt.Sprintf("%s-%s.json", shortName, sufix)
-		outputFilePath := filepath.Join(outputDir, outputFileName)
-		if err := ioutil.WriteFile(outputFilePath, data, 0644); err != nil {
-			log.Fatalf("Failed to wrtie file %s: %v", outputFileName, err)
-		}
-		fmt.Println(outputFilePath)
-	} else {
-		// Save the replication controller to Yaml file
-		var exp interface{}
-		// because yaml is not directly usable from api, we can unmarshal json to interface{}
-		// and then write yaml file
-		// yaml segfaults on serializing srv directly
-		json.Unmarshal(data, &exp)
-		data, err = yaml.Marshal(exp)
-		if err != nil {
-			log.Fatalf("Failed to marshal file %s-%s: %v", shortName, sufix, err)
+	return rancherCompose
+}
+
+// convertProject converts the services of the project to Kubernetes
+// objects, logs the warnings of the conversion and writes its report. The
+// errors of the services are all logged before exiting, or with
+// -skip-failing-services logged as the services left out, which still fails
+// the run with -strict before anything is written.
+func convertProject(p *converter.Project) []converter.Object {
+	result, err := conv.Convert(p)
+	if result != nil {
+		writeReport(result.Report)
+	}
+	if errors, ok := err.(converter.Errors); ok {
+		for _, warning := range result.Warnings {
+			log.Printf("Warning: %s", warning)
 		}
-		// Save the replication controller for the Docker compose service to the
-		// configs directory.
-		outputFileName := fmt.Sprintf("%s-%s.yml", shortName, sufix)
-		outputFilePath := filepath.Join(outputDir, outputFileName)
-		if err := ioutil.WriteFile(outputFilePath, data, 0644); err != nil {
-			log.Fatalf("Failed to write service %s: %v", outputFileName, err)
+		for _, e := range errors {
+			log.Printf("Error: %s", e)
 		}
-		fmt.Println(outputFilePath)
+		log.Printf("Failed to convert the project, see the errors above")
+		os.Exit(conversionErrorsExitCode)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, warning := range result.Warnings {
+		log.Printf("Warning: %s", warning)
 	}
+	for _, e := range result.Errors {
+		log.Printf("Warning: %s, leaving the service out", e)
+		servicesLeftOut = true
+	}
+	return result.Objects
 }
 
-func processDockerCompose(dockerCompose *project.Project, rancherCompose map[interface{}]interface{}) {
-	for _, name := range dockerCompose.ServiceConfigs.Keys() {
-		service, ok := dockerCompose.ServiceConfigs.Get(name)
-		if !ok {
-			log.Fatalf("Failed to get key %s from config", name)
-		}
-
-		shortName := name
-		if len(name) > 24 {
-			shortName = name[0:24]
-		}
+// marshalObject encodes a versioned object in the output format and returns
+// the extension of the file holding it.
+func marshalObject(object map[string]interface{}) ([]byte, string, error) {
+	if outputFormat == "json" {
+		data, err := json.MarshalIndent(object, "", "  ")
+		return data, "json", err
+	}
+	data, err := yaml.Marshal(object)
+	return data, "yml", err
+}
 
-		rc := createReplicationController(name, shortName, service, rancherCompose)
-		cleanServices(name, rancherCompose)
-		writeFile(shortName, "rc", rc)
+func writeFile(dir string, shortName string, sufix string, object map[string]interface{}) {
+	if outputFormat == "helm" {
+		writeHelmTemplate(dir, shortName, sufix, object)
+		return
+	}
+	data, extension, err := marshalObject(object)
+	if err != nil {
+		log.Fatalf("Failed to marshal file %s-%s: %v", shortName, sufix, err)
+	}
 
-		srv := createService(shortName, service, rc)
-		writeFile(shortName, "srv", srv)
+	// Save the object for the Docker compose service to the configs directory.
+	outputFileName := fmt.Sprintf("%s-%s.%s", shortName, sufix, extension)
+	outputFile(filepath.Join(dir, outputFileName), data)
+}
 
+// processDockerCompose writes the objects to dir.
+func processDockerCompose(dir string, objects []converter.Object) {
+	for _, object := range objects {
+		writeFile(dir, object.Name, object.Suffix, object.Versioned)
 	}
 }
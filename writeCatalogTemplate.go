@@ -0,0 +1,112 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/kelseyhightower/compose2kube/converter"
+
+	"gopkg.in/yaml.v2"
+)
+
+// catalogConfigKeys are the .catalog keys carried over to config.yml.
+var catalogConfigKeys = []string{"name", "description", "version", "minimum_rancher_version", "category", "maintainer", "license", "projectURL"}
+
+// nextCatalogVersion returns the number of the version folder following the
+// ones already in dir, and the catalog version of the latest of them.
+func nextCatalogVersion(dir string) (int, string) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		log.Fatalf("Failed to list the catalog template %s: %v", dir, err)
+	}
+	latest := -1
+	for _, entry := range entries {
+		if number, err := strconv.Atoi(entry.Name()); err == nil && entry.IsDir() && number > latest {
+			latest = number
+		}
+	}
+	if latest == -1 {
+		return 0, ""
+	}
+	var previous struct {
+		Catalog struct {
+			Version string `yaml:"version"`
+		} `yaml:".catalog"`
+	}
+	if file, err := ioutil.ReadFile(filepath.Join(dir, strconv.Itoa(latest), "rancher-compose.yml")); err == nil {
+		yaml.Unmarshal(file, &previous)
+	}
+	return latest + 1, previous.Catalog.Version
+}
+
+// writeCatalogTemplate writes a Rancher catalog template: config.yml and the
+// catalog icon in the output directory, and the manifests and
+// rancher-compose.yml in a new numbered version folder.
+func writeCatalogTemplate(p *converter.Project) {
+	catalog := p.Rancher.Catalog
+	if catalog == nil {
+		log.Fatalf("A catalog template needs the .catalog section of %s", p.Rancher.Path())
+	}
+	templateDir := outputDir
+	next, previousVersion := nextCatalogVersion(templateDir)
+	if catalog.Version != "" && catalog.Version == previousVersion {
+		log.Fatalf("Version %s is already in the catalog template %s, bump the version of .catalog", catalog.Version, templateDir)
+	}
+
+	values := map[string]interface{}{
+		"name":                    catalog.Name,
+		"description":             catalog.Description,
+		"version":                 catalog.Version,
+		"minimum_rancher_version": catalog.MinimumRancherVersion,
+	}
+	for key, value := range catalog.Extra {
+		values[key] = value
+	}
+	config := yaml.MapSlice{}
+	for _, key := range catalogConfigKeys {
+		if value, ok := values[key]; ok && value != "" {
+			config = append(config, yaml.MapItem{Key: key, Value: value})
+		}
+	}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		log.Fatalf("Failed to marshal config.yml: %v", err)
+	}
+	outputFile(filepath.Join(templateDir, "config.yml"), data)
+
+	icons, err := filepath.Glob(composeFilePath + "catalogIcon*")
+	if err != nil {
+		log.Fatalf("Failed to look for the catalog icon: %v", err)
+	}
+	for _, icon := range icons {
+		data, err := ioutil.ReadFile(icon)
+		if err != nil {
+			log.Fatalf("Failed to read the catalog icon %s: %v", icon, err)
+		}
+		outputFile(filepath.Join(templateDir, filepath.Base(icon)), data)
+	}
+	if len(icons) == 0 {
+		log.Printf("Warning: no catalogIcon file found in %s, the template has no icon", composeFilePath)
+	}
+
+	// The manifests are written by the yaml format into the version folder.
+	versionDir := filepath.Join(templateDir, strconv.Itoa(next))
+	processDockerCompose(versionDir, convertProject(p))
+	processRancherCompose(versionDir, p)
+}
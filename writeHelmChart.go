@@ -0,0 +1,354 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/kelseyhightower/compose2kube/converter"
+)
+
+// helmValues holds the values lifted out of the chart templates, keyed by
+// service. helmVariables holds the defaults of the compose variables the
+// templates refer to, read from the questionsValuesKey values.
+var (
+	helmValues    = map[string]map[string]interface{}{}
+	helmVariables = map[string]string{}
+)
+
+// questionsValuesKey is the values key holding the answers of the catalog
+// questions, apart from the service keys.
+const questionsValuesKey = "questions"
+
+var helmSentinelRegexp = regexp.MustCompile(`'?__helm_value_([0-9]+)__'?`)
+
+// helmTemplate turns a versioned object into a chart template. Fields lifted
+// into values are first replaced by sentinels, which are swapped for
+// template actions once the object is marshalled.
+type helmTemplate struct {
+	actions []string
+	blocks  map[int]bool
+}
+
+func (t *helmTemplate) lift(action string, block bool) string {
+	if t.blocks == nil {
+		t.blocks = map[int]bool{}
+	}
+	t.blocks[len(t.actions)] = block
+	t.actions = append(t.actions, action)
+	return fmt.Sprintf("__helm_value_%d__", len(t.actions)-1)
+}
+
+func (t *helmTemplate) render(data []byte) []byte {
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		lines[i] = helmSentinelRegexp.ReplaceAllStringFunc(line, func(sentinel string) string {
+			var index int
+			fmt.Sscanf(strings.Trim(sentinel, "'"), "__helm_value_%d__", &index)
+			if !t.blocks[index] {
+				return t.actions[index]
+			}
+			// Block values are rendered on the lines following their key.
+			indent := len(line) - len(strings.TrimLeft(line, " "))
+			if strings.HasPrefix(strings.TrimLeft(line, " "), "- ") {
+				indent += 2
+			}
+			return fmt.Sprintf("{{- toYaml %s | nindent %d }}", t.actions[index], indent+2)
+		})
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// valuesKey returns the lowerCamelCase key holding the values of a service.
+func valuesKey(shortName string) string {
+	parts := converter.NonAlphanumRegexp.Split(shortName, -1)
+	key := parts[0]
+	for _, part := range parts[1:] {
+		if part != "" {
+			key += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return key
+}
+
+// splitImage splits an image reference into its repository and tag.
+func splitImage(image string) (string, string) {
+	if strings.Contains(image, "@") {
+		return image, ""
+	}
+	colon := strings.LastIndex(image, ":")
+	if colon == -1 || strings.Contains(image[colon:], "/") {
+		return image, "latest"
+	}
+	return image[:colon], image[colon+1:]
+}
+
+func writeHelmTemplate(dir string, shortName string, sufix string, object map[string]interface{}) {
+	key := valuesKey(shortName)
+	values := helmValues[key]
+	if values == nil {
+		values = map[string]interface{}{}
+		helmValues[key] = values
+	}
+	ref := ".Values." + key
+	t := &helmTemplate{}
+
+	if metadata, ok := object["metadata"].(map[string]interface{}); ok {
+		metadata["namespace"] = t.lift("{{ .Release.Namespace }}", false)
+	}
+
+	kind, _ := object["kind"].(string)
+	spec, _ := object["spec"].(map[string]interface{})
+	if replicas, ok := spec["replicas"].(float64); ok {
+		values["replicas"] = int(replicas)
+		spec["replicas"] = t.lift("{{ "+ref+".replicas }}", false)
+	}
+	if kind == "Service" {
+		serviceType, _ := spec["type"].(string)
+		if serviceType == "" {
+			serviceType = "ClusterIP"
+		}
+		values["service"] = map[string]interface{}{"type": serviceType}
+		spec["type"] = t.lift("{{ "+ref+".service.type }}", false)
+	}
+
+	containers, _ := converter.GetField(converter.PodSpecOf(object), "containers").([]interface{})
+	if len(containers) > 0 {
+		container := containers[0].(map[string]interface{})
+		// Images and values made of compose variables stay in the template,
+		// values.yaml is not rendered.
+		if image, ok := container["image"].(string); ok && !strings.Contains(image, "$") {
+			repository, tag := splitImage(image)
+			values["image"] = map[string]interface{}{"repository": repository, "tag": tag}
+			action := "{{ " + ref + ".image.repository }}:{{ " + ref + ".image.tag }}"
+			if tag == "" {
+				action = "{{ " + ref + ".image.repository }}"
+			}
+			container["image"] = t.lift(action, false)
+		}
+		resources, _ := container["resources"].(map[string]interface{})
+		if resources == nil {
+			resources = map[string]interface{}{}
+		}
+		values["resources"] = resources
+		container["resources"] = t.lift(ref+".resources", true)
+
+		env, _ := container["env"].([]interface{})
+		envValues := map[string]interface{}{}
+		for _, item := range env {
+			variable, _ := item.(map[string]interface{})
+			name, _ := variable["name"].(string)
+			value, ok := variable["value"].(string)
+			if !ok || strings.Contains(value, "$") {
+				continue
+			}
+			envValues[name] = value
+			variable["value"] = t.lift(fmt.Sprintf("{{ index %s.env %q | quote }}", ref, name), false)
+		}
+		if len(envValues) > 0 {
+			values["env"] = envValues
+		}
+	}
+
+	templateVariables(object)
+
+	data, err := yaml.Marshal(object)
+	if err != nil {
+		log.Fatalf("Failed to marshal file %s-%s: %v", shortName, sufix, err)
+	}
+	outputFile(filepath.Join(dir, "templates", fmt.Sprintf("%s-%s.yaml", shortName, sufix)), t.render(data))
+}
+
+// templateVariables replaces the compose variables found in strings with the
+// chart values of the same name.
+func templateVariables(object interface{}) interface{} {
+	switch object := object.(type) {
+	case map[string]interface{}:
+		for key, value := range object {
+			object[key] = templateVariables(value)
+		}
+	case []interface{}:
+		for i, item := range object {
+			object[i] = templateVariables(item)
+		}
+	case string:
+		return converter.ComposeVariableRegexp.ReplaceAllStringFunc(object, func(reference string) string {
+			variable := converter.ParseComposeVariable(reference)
+			if variable.Name == "NAMESPACE" {
+				return "{{ .Release.Namespace }}"
+			}
+			if _, ok := helmVariables[variable.Name]; !ok || variable.HasDefault {
+				helmVariables[variable.Name] = variable.Default
+			}
+			action := ".Values." + questionsValuesKey + "." + variable.Name
+			if variable.DefaultIfEmpty {
+				action += fmt.Sprintf(" | default %q", variable.Default)
+			}
+			return "{{ " + action + " }}"
+		})
+	}
+	return object
+}
+
+// questionSchema describes the chart value of a Rancher catalog question.
+func questionSchema(question converter.RancherQuestion) map[string]interface{} {
+	schema := map[string]interface{}{"type": "string"}
+	switch question.Type {
+	case "int":
+		schema["type"] = "integer"
+	case "boolean":
+		schema["type"] = "boolean"
+	case "enum":
+		schema["enum"] = question.Options
+	}
+	if question.Label != "" {
+		schema["title"] = question.Label
+	}
+	if question.Description != "" {
+		schema["description"] = question.Description
+	}
+	return schema
+}
+
+// serviceSchema describes the values lifted for a service.
+func serviceSchema(values map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	if _, ok := values["image"]; ok {
+		properties["image"] = map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"repository": map[string]interface{}{"type": "string"},
+				"tag":        map[string]interface{}{"type": "string"},
+			},
+		}
+	}
+	if _, ok := values["replicas"]; ok {
+		properties["replicas"] = map[string]interface{}{"type": "integer", "minimum": 0}
+	}
+	if _, ok := values["resources"]; ok {
+		properties["resources"] = map[string]interface{}{"type": "object"}
+	}
+	if _, ok := values["env"]; ok {
+		properties["env"] = map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": map[string]interface{}{"type": "string"},
+		}
+	}
+	if _, ok := values["service"]; ok {
+		properties["service"] = map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"type": map[string]interface{}{
+					"type": "string",
+					"enum": []string{"ClusterIP", "NodePort", "LoadBalancer"},
+				},
+			},
+		}
+	}
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+// writeHelmChart writes Chart.yaml, values.yaml and values.schema.json next
+// to the templates written by writeHelmTemplate.
+func writeHelmChart(rancherCompose *converter.RancherCompose) {
+	absOutputDir, err := filepath.Abs(outputDir)
+	if err != nil {
+		log.Fatalf("Failed to resolve the output directory %s: %v", outputDir, err)
+	}
+	chart := map[string]interface{}{
+		"apiVersion":  "v2",
+		"name":        filepath.Base(absOutputDir),
+		"description": "Generated by compose2kube",
+		"type":        "application",
+		"version":     "0.1.0",
+	}
+	if catalog := rancherCompose.Catalog; catalog != nil {
+		if catalog.Description != "" {
+			chart["description"] = catalog.Description
+		}
+		if catalog.Version != "" {
+			chart["version"] = catalog.Version
+			chart["appVersion"] = catalog.Version
+		}
+	}
+
+	values := map[string]interface{}{}
+	properties := map[string]interface{}{}
+	for key, serviceValues := range helmValues {
+		values[key] = serviceValues
+		properties[key] = serviceSchema(serviceValues)
+	}
+	answers := map[string]interface{}{}
+	answerProperties := map[string]interface{}{}
+	var required []string
+	questions := rancherCompose.Questions()
+	asked := map[string]bool{}
+	for _, question := range questions {
+		asked[question.Variable] = true
+	}
+	for variable, defaultValue := range helmVariables {
+		if !asked[variable] {
+			log.Printf("Warning: variable %s has no catalog question, defaulting it to %q", variable, defaultValue)
+			questions = append(questions, converter.RancherQuestion{Variable: variable, Default: defaultValue})
+		}
+	}
+	for _, question := range questions {
+		if question.Variable == "NAMESPACE" {
+			continue
+		}
+		answers[question.Variable] = question.DefaultValue()
+		answerProperties[question.Variable] = questionSchema(question)
+		if question.Required {
+			required = append(required, question.Variable)
+		}
+	}
+	if len(answers) > 0 {
+		if _, ok := values[questionsValuesKey]; ok {
+			log.Fatalf("Service %s clashes with the %s values of the catalog questions, rename it", questionsValuesKey, questionsValuesKey)
+		}
+		sort.Strings(required)
+		answersSchema := map[string]interface{}{"type": "object", "properties": answerProperties}
+		if len(required) > 0 {
+			answersSchema["required"] = required
+		}
+		values[questionsValuesKey] = answers
+		properties[questionsValuesKey] = answersSchema
+	}
+	schema := map[string]interface{}{
+		"$schema":    "http://json-schema.org/draft-07/schema#",
+		"type":       "object",
+		"properties": properties,
+	}
+
+	writeChartFile("Chart.yaml", chart, yaml.Marshal)
+	writeChartFile("values.yaml", values, yaml.Marshal)
+	writeChartFile("values.schema.json", schema, func(object interface{}) ([]byte, error) {
+		return json.MarshalIndent(object, "", "  ")
+	})
+}
+
+func writeChartFile(name string, object interface{}, marshal func(interface{}) ([]byte, error)) {
+	data, err := marshal(object)
+	if err != nil {
+		log.Fatalf("Failed to marshal file %s: %v", name, err)
+	}
+	outputFile(filepath.Join(outputDir, name), data)
+}
@@ -0,0 +1,266 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/kelseyhightower/compose2kube/converter"
+)
+
+// composeOverrides returns the docker-compose.<environment>.yml files found
+// next to docker-compose.yml, keyed by environment.
+func composeOverrides() map[string]string {
+	files, err := filepath.Glob(composeFilePath + "docker-compose.*.yml")
+	if err != nil {
+		log.Fatalf("Failed to list the compose override files: %v", err)
+	}
+	overrides := make(map[string]string, len(files))
+	for _, file := range files {
+		environment := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "docker-compose."), ".yml")
+		overrides[environment] = file
+	}
+	return overrides
+}
+
+// kustomizeObjects strips the ${NAMESPACE} placeholder, kustomize sets the
+// namespace of the objects itself.
+func kustomizeObjects(generated []converter.Object) map[string]converter.Object {
+	objects := make(map[string]converter.Object, len(generated))
+	for _, g := range generated {
+		if metadata, ok := g.Versioned["metadata"].(map[string]interface{}); ok && metadata["namespace"] == "${NAMESPACE}" {
+			delete(metadata, "namespace")
+		}
+		objects[fmt.Sprintf("%s-%s.yml", g.Name, g.Suffix)] = g
+	}
+	return objects
+}
+
+// writeKustomize writes the compose project as a kustomize base and an
+// overlay for every compose override file.
+func writeKustomize(dockerCompose string) {
+	base := kustomizeObjects(convertProject(parseComposeFiles(dockerCompose)))
+	baseDir := filepath.Join(outputDir, "base")
+	resources := sortedFiles(base)
+	for _, file := range resources {
+		writeKustomizeFile(baseDir, file, base[file].Versioned)
+	}
+	writeKustomizeFile(baseDir, "kustomization.yaml", kustomization(resources))
+
+	shared := sharedImages(base)
+	for environment, override := range composeOverrides() {
+		overlay := kustomizeObjects(convertProject(parseComposeFiles(dockerCompose, override)))
+		overlayDir := filepath.Join(outputDir, "overlays", environment)
+		resources := []string{"../../base"}
+		var patches []interface{}
+		images := map[string]map[string]interface{}{}
+		for _, file := range sortedFiles(overlay) {
+			g := overlay[file]
+			b, ok := base[file]
+			if !ok {
+				// Services only found in the override are added as is.
+				writeKustomizeFile(overlayDir, file, g.Versioned)
+				resources = append(resources, file)
+				continue
+			}
+			diffImages(b.Versioned, g.Versioned, shared, images)
+			patch, changed := strategicDiff("", b.Versioned, g.Versioned)
+			if !changed {
+				continue
+			}
+			patchFile := fmt.Sprintf("%s-%s-patch.yml", g.Name, g.Suffix)
+			writeKustomizeFile(overlayDir, patchFile, patchTarget(g.Versioned, patch.(map[string]interface{})))
+			patches = append(patches, map[string]interface{}{"path": patchFile})
+		}
+		k := kustomization(resources)
+		if len(patches) > 0 {
+			k["patches"] = patches
+		}
+		if len(images) > 0 {
+			var names []string
+			for name := range images {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			var entries []interface{}
+			for _, name := range names {
+				entries = append(entries, images[name])
+			}
+			k["images"] = entries
+		}
+		writeKustomizeFile(overlayDir, "kustomization.yaml", k)
+	}
+}
+
+func sortedFiles(objects map[string]converter.Object) []string {
+	files := make([]string, 0, len(objects))
+	for file := range objects {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func kustomization(resources []string) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "kustomize.config.k8s.io/v1beta1",
+		"kind":       "Kustomization",
+		"resources":  resources,
+	}
+}
+
+// patchTarget adds to a patch the fields kustomize uses to find the object
+// it applies to.
+func patchTarget(object map[string]interface{}, patch map[string]interface{}) map[string]interface{} {
+	patch["apiVersion"] = object["apiVersion"]
+	patch["kind"] = object["kind"]
+	metadata, _ := patch["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+		patch["metadata"] = metadata
+	}
+	metadata["name"] = converter.GetField(object, "metadata", "name")
+	return patch
+}
+
+// sharedImages returns the images run by more than one container of the
+// base objects, which a kustomize images entry would change together.
+func sharedImages(base map[string]converter.Object) map[string]bool {
+	containers := map[string]int{}
+	for _, object := range base {
+		for _, item := range podContainers(object.Versioned) {
+			container, _ := item.(map[string]interface{})
+			image, _ := container["image"].(string)
+			name, _ := splitImage(image)
+			containers[name]++
+		}
+	}
+	shared := map[string]bool{}
+	for name, count := range containers {
+		shared[name] = count > 1
+	}
+	return shared
+}
+
+// diffImages records the image changes between the containers of base and
+// overlay as kustomize images entries. The overlay images are reset so the
+// change is not repeated in the patch. The changes of shared images are
+// left to the patch of the container.
+func diffImages(base map[string]interface{}, overlay map[string]interface{}, shared map[string]bool, images map[string]map[string]interface{}) {
+	baseImages := map[string]string{}
+	for _, item := range podContainers(base) {
+		container, _ := item.(map[string]interface{})
+		name, _ := container["name"].(string)
+		baseImages[name], _ = container["image"].(string)
+	}
+	for _, item := range podContainers(overlay) {
+		container, _ := item.(map[string]interface{})
+		name, _ := container["name"].(string)
+		image, _ := container["image"].(string)
+		baseImage, ok := baseImages[name]
+		if !ok || baseImage == image {
+			continue
+		}
+		baseName, _ := splitImage(baseImage)
+		if shared[baseName] {
+			continue
+		}
+		newName, newTag := splitImage(image)
+		entry := map[string]interface{}{"name": baseName}
+		if newName != baseName {
+			entry["newName"] = newName
+		}
+		if newTag != "" {
+			entry["newTag"] = newTag
+		}
+		images[baseName] = entry
+		container["image"] = baseImage
+	}
+}
+
+func podContainers(object map[string]interface{}) []interface{} {
+	containers, _ := converter.GetField(converter.PodSpecOf(object), "containers").([]interface{})
+	return containers
+}
+
+// strategicDiff returns the strategic merge patch turning base into overlay.
+// The lists of the strategic merge keys of the converter, such as containers
+// by name or Service ports by port, are merged by key; other lists are
+// written out whole.
+func strategicDiff(field string, base interface{}, overlay interface{}) (interface{}, bool) {
+	switch overlay := overlay.(type) {
+	case map[string]interface{}:
+		base, ok := base.(map[string]interface{})
+		if !ok {
+			return overlay, true
+		}
+		patch := map[string]interface{}{}
+		for key, value := range overlay {
+			if diff, changed := strategicDiff(key, base[key], value); changed {
+				patch[key] = diff
+			}
+		}
+		for key := range base {
+			if _, ok := overlay[key]; !ok {
+				patch[key] = nil
+			}
+		}
+		return patch, len(patch) > 0
+	case []interface{}:
+		base, ok := base.([]interface{})
+		key := converter.ListMergeKey(field, base, overlay)
+		if !ok || key == "" {
+			return overlay, !reflect.DeepEqual(base, overlay)
+		}
+		baseItems := map[string]interface{}{}
+		for _, item := range base {
+			baseItems[fmt.Sprint(item.(map[string]interface{})[key])] = item
+		}
+		var patch []interface{}
+		for _, item := range overlay {
+			value := item.(map[string]interface{})[key]
+			diff, changed := strategicDiff("", baseItems[fmt.Sprint(value)], item)
+			if changed {
+				diff.(map[string]interface{})[key] = value
+				patch = append(patch, diff)
+			}
+			delete(baseItems, fmt.Sprint(value))
+		}
+		var deleted []string
+		for value := range baseItems {
+			deleted = append(deleted, value)
+		}
+		sort.Strings(deleted)
+		for _, value := range deleted {
+			item := baseItems[value].(map[string]interface{})
+			patch = append(patch, map[string]interface{}{key: item[key], "$patch": "delete"})
+		}
+		return patch, len(patch) > 0
+	}
+	return overlay, !reflect.DeepEqual(base, overlay)
+}
+
+func writeKustomizeFile(dir string, name string, object interface{}) {
+	data, err := yaml.Marshal(object)
+	if err != nil {
+		log.Fatalf("Failed to marshal file %s: %v", name, err)
+	}
+	outputFile(filepath.Join(dir, name), data)
+}
@@ -0,0 +1,335 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/libcompose/config"
+	"github.com/kelseyhightower/compose2kube/converter"
+)
+
+// httpPorts are the container ports exposed through a Route when the
+// service publishes them and has no routesLabel. TLS ports are passed
+// through to the pods.
+var httpPorts = map[int]bool{80: false, 443: true, 8000: false, 8080: false, 8443: true}
+
+// routesLabel lists the container ports of a service exposed through a
+// Route, such as "3000,9443/tls", in place of the published httpPorts. An
+// empty list leaves the service without Route.
+const routesLabel = "compose2kube.routes"
+
+// writeOpenShiftTemplate writes the compose project as a single OpenShift
+// Template. Deployments and replication controllers become
+// DeploymentConfigs, published HTTP ports get a Route and services built
+// from source get an ImageStream.
+func writeOpenShiftTemplate(p *converter.Project) {
+	var objects []interface{}
+	for _, g := range convertProject(p) {
+		// Templates are instantiated in the namespace of the user.
+		if metadata, ok := g.Versioned["metadata"].(map[string]interface{}); ok {
+			delete(metadata, "namespace")
+		}
+		built := g.Service.Build.Context != ""
+		switch g.Versioned["kind"] {
+		case "Deployment", "ReplicationController":
+			if built {
+				objects = append(objects, imageStream(g.Name))
+			}
+			objects = append(objects, deploymentConfig(g.Name, g.Versioned, built))
+		case "Service":
+			objects = append(objects, g.Versioned)
+			objects = append(objects, routes(g.Name, g.Service)...)
+		default:
+			if built {
+				objects = append(objects, imageStream(g.Name))
+			}
+			objects = append(objects, g.Versioned)
+		}
+	}
+
+	name, description := templateName(p.Rancher)
+	// The parameters are read first, the refs lose the variable defaults.
+	parameters := templateParameters(objects, p.Rancher)
+	template := map[string]interface{}{
+		"apiVersion": "template.openshift.io/v1",
+		"kind":       "Template",
+		"metadata": map[string]interface{}{
+			"name": name,
+			"annotations": map[string]interface{}{
+				"description": description,
+			},
+		},
+		"objects":    templateParameterRefs(objects),
+		"parameters": parameters,
+	}
+
+	data, ext, err := marshalObject(template)
+	if err != nil {
+		log.Fatalf("Failed to marshal template %s: %v", name, err)
+	}
+	outputFile(filepath.Join(outputDir, fmt.Sprintf("%s-template.%s", name, ext)), data)
+}
+
+// templateName returns the name and description of the template, taken from
+// the .catalog section of rancher-compose or the output directory.
+func templateName(rancherCompose *converter.RancherCompose) (string, string) {
+	absOutputDir, err := filepath.Abs(outputDir)
+	if err != nil {
+		log.Fatalf("Failed to resolve the output directory %s: %v", outputDir, err)
+	}
+	name := filepath.Base(absOutputDir)
+	description := "Generated by compose2kube"
+	if catalog := rancherCompose.Catalog; catalog != nil {
+		if catalog.Name != "" {
+			name = strings.ToLower(converter.NonAlphanumRegexp.ReplaceAllString(catalog.Name, "-"))
+		}
+		if catalog.Description != "" {
+			description = catalog.Description
+		}
+	}
+	return name, description
+}
+
+// deploymentConfig turns a versioned Deployment or ReplicationController
+// into a DeploymentConfig. Services built from source are redeployed when
+// their ImageStream is updated.
+func deploymentConfig(shortName string, object map[string]interface{}, built bool) map[string]interface{} {
+	spec, _ := object["spec"].(map[string]interface{})
+	selector, _ := converter.GetField(spec, "selector", "matchLabels").(map[string]interface{})
+	if object["kind"] == "ReplicationController" {
+		selector, _ = spec["selector"].(map[string]interface{})
+	}
+	dcSpec := map[string]interface{}{
+		"replicas": spec["replicas"],
+		"selector": selector,
+		"template": spec["template"],
+		"strategy": map[string]interface{}{"type": "Rolling"},
+	}
+	if rollingUpdate, ok := converter.GetField(spec, "strategy", "rollingUpdate").(map[string]interface{}); ok {
+		dcSpec["strategy"].(map[string]interface{})["rollingParams"] = rollingUpdate
+	}
+	if minReadySeconds, ok := spec["minReadySeconds"]; ok {
+		dcSpec["minReadySeconds"] = minReadySeconds
+	}
+	triggers := []interface{}{map[string]interface{}{"type": "ConfigChange"}}
+	if built {
+		for _, item := range podContainers(object) {
+			container := item.(map[string]interface{})
+			if image, _ := container["image"].(string); container["name"] == shortName && image == "" {
+				container["image"] = shortName + ":latest"
+			}
+		}
+		triggers = append(triggers, map[string]interface{}{
+			"type": "ImageChange",
+			"imageChangeParams": map[string]interface{}{
+				"automatic":      true,
+				"containerNames": []string{shortName},
+				"from": map[string]interface{}{
+					"kind": "ImageStreamTag",
+					"name": shortName + ":latest",
+				},
+			},
+		})
+	}
+	dcSpec["triggers"] = triggers
+	return map[string]interface{}{
+		"apiVersion": "apps.openshift.io/v1",
+		"kind":       "DeploymentConfig",
+		"metadata":   object["metadata"],
+		"spec":       dcSpec,
+	}
+}
+
+func imageStream(shortName string) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "image.openshift.io/v1",
+		"kind":       "ImageStream",
+		"metadata": map[string]interface{}{
+			"name":   shortName,
+			"labels": map[string]interface{}{"service": shortName},
+		},
+	}
+}
+
+// routes returns a Route for every port of the routesLabel of the service,
+// or for every HTTP port it publishes on the host.
+func routes(shortName string, service *config.ServiceConfig) []interface{} {
+	published, tls := routePorts(shortName, service)
+	var objects []interface{}
+	for _, port := range published {
+		name := shortName
+		if len(published) > 1 {
+			name = fmt.Sprintf("%s-%d", shortName, port)
+		}
+		spec := map[string]interface{}{
+			"to":   map[string]interface{}{"kind": "Service", "name": shortName},
+			"port": map[string]interface{}{"targetPort": port},
+		}
+		if tls[port] {
+			spec["tls"] = map[string]interface{}{"termination": "passthrough"}
+		}
+		objects = append(objects, map[string]interface{}{
+			"apiVersion": "route.openshift.io/v1",
+			"kind":       "Route",
+			"metadata": map[string]interface{}{
+				"name":   name,
+				"labels": map[string]interface{}{"service": shortName},
+			},
+			"spec": spec,
+		})
+	}
+	return objects
+}
+
+// routePorts returns the ports of the Routes of a service and whether they
+// pass TLS through.
+func routePorts(shortName string, service *config.ServiceConfig) ([]int, map[int]bool) {
+	var ports []int
+	tls := map[int]bool{}
+	if label, ok := service.Labels[routesLabel]; ok {
+		for _, port := range strings.Split(label, ",") {
+			port = strings.TrimSpace(port)
+			if port == "" {
+				continue
+			}
+			number, err := strconv.Atoi(strings.TrimSuffix(port, "/tls"))
+			if err != nil || number < 1 || number > 65535 {
+				log.Fatalf("Service %s: invalid port %s in the %s label", shortName, port, routesLabel)
+			}
+			ports = append(ports, number)
+			tls[number] = strings.HasSuffix(port, "/tls")
+		}
+		return ports, tls
+	}
+	for _, port := range service.Ports {
+		port = strings.TrimSpace(strings.Trim(port, "\""))
+		parts := strings.Split(port, ":")
+		if len(parts) < 2 {
+			continue
+		}
+		number, err := strconv.Atoi(parts[len(parts)-1])
+		if err != nil {
+			continue
+		}
+		if passthrough, ok := httpPorts[number]; ok {
+			ports = append(ports, number)
+			tls[number] = passthrough
+		}
+	}
+	return ports, tls
+}
+
+// templateParameterRefs rewrites the compose variables of the objects to the
+// ${VAR} form OpenShift substitutes, their defaults go to the parameters.
+func templateParameterRefs(object interface{}) interface{} {
+	switch object := object.(type) {
+	case map[string]interface{}:
+		for key, value := range object {
+			object[key] = templateParameterRefs(value)
+		}
+	case []interface{}:
+		for i, item := range object {
+			object[i] = templateParameterRefs(item)
+		}
+	case string:
+		return converter.ComposeVariableRegexp.ReplaceAllStringFunc(object, func(reference string) string {
+			return "${" + converter.ParseComposeVariable(reference).Name + "}"
+		})
+	}
+	return object
+}
+
+// collectVariables records the compose variables found in the strings of
+// object, in the order they are found. A variable with a default is
+// recorded with the first one.
+func collectVariables(object interface{}, found map[string]bool, variables *[]converter.ComposeVariable) {
+	switch object := object.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			collectVariables(object[key], found, variables)
+		}
+	case []interface{}:
+		for _, item := range object {
+			collectVariables(item, found, variables)
+		}
+	case string:
+		for _, reference := range converter.ComposeVariableRegexp.FindAllString(object, -1) {
+			variable := converter.ParseComposeVariable(reference)
+			if !found[variable.Name] {
+				found[variable.Name] = true
+				*variables = append(*variables, variable)
+				continue
+			}
+			for i := range *variables {
+				if (*variables)[i].Name == variable.Name && !(*variables)[i].HasDefault {
+					(*variables)[i] = variable
+				}
+			}
+		}
+	}
+}
+
+// templateParameters returns the catalog questions as template parameters,
+// followed by the compose variables no question answers.
+func templateParameters(objects []interface{}, rancherCompose *converter.RancherCompose) []interface{} {
+	var parameters []interface{}
+	asked := map[string]bool{"NAMESPACE": true}
+	for _, question := range rancherCompose.Questions() {
+		if asked[question.Variable] {
+			continue
+		}
+		asked[question.Variable] = true
+		parameter := map[string]interface{}{"name": question.Variable}
+		if question.Label != "" {
+			parameter["displayName"] = question.Label
+		}
+		if question.Description != "" {
+			parameter["description"] = question.Description
+		}
+		if value := fmt.Sprint(question.DefaultValue()); value != "" {
+			parameter["value"] = value
+		}
+		if question.Required {
+			parameter["required"] = true
+		}
+		parameters = append(parameters, parameter)
+	}
+
+	var variables []converter.ComposeVariable
+	collectVariables(objects, map[string]bool{}, &variables)
+	for _, variable := range variables {
+		if asked[variable.Name] {
+			continue
+		}
+		asked[variable.Name] = true
+		if variable.HasDefault {
+			parameters = append(parameters, map[string]interface{}{"name": variable.Name, "value": variable.Default})
+			continue
+		}
+		log.Printf("Warning: variable %s has no catalog question, adding it as a required parameter", variable.Name)
+		parameters = append(parameters, map[string]interface{}{"name": variable.Name, "required": true})
+	}
+	return parameters
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2016 Kelsey Hightower All rights reserved.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kelseyhightower/compose2kube/converter"
+)
+
+// reportWritten is set once the report is written, the kustomize overlays
+// convert the project again.
+var reportWritten bool
+
+// writeReport writes the report of the conversion in the -report format,
+// to -report-file or the standard output.
+func writeReport(report converter.Report) {
+	if reportFormat == "" || reportWritten {
+		return
+	}
+	reportWritten = true
+	var buf bytes.Buffer
+	switch reportFormat {
+	case "text":
+		writeTextReport(&buf, report)
+	case "json":
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to marshal the report: %v", err)
+		}
+		buf.Write(append(data, '\n'))
+	case "markdown":
+		writeMarkdownReport(&buf, report)
+	default:
+		log.Fatalf("Unknown report format %s, expected text, json or markdown", reportFormat)
+	}
+	if reportFile == "" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	if err := ioutil.WriteFile(reportFile, buf.Bytes(), 0644); err != nil {
+		log.Fatalf("Failed to write file %s: %v", reportFile, err)
+	}
+	fmt.Println(reportFile)
+}
+
+func writeTextReport(w io.Writer, report converter.Report) {
+	for i, service := range report {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s\n", service.Service)
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		for _, key := range service.Keys {
+			detail := key.Target
+			if key.Note != "" {
+				detail = strings.TrimPrefix(detail+": "+key.Note, ": ")
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", key.Key, key.Status, detail)
+		}
+		tw.Flush()
+	}
+}
+
+func writeMarkdownReport(w io.Writer, report converter.Report) {
+	fmt.Fprintln(w, "# Conversion report")
+	for _, service := range report {
+		fmt.Fprintf(w, "\n## %s\n\n", service.Service)
+		fmt.Fprintln(w, "| Key | Status | Kubernetes | Note |")
+		fmt.Fprintln(w, "| --- | --- | --- | --- |")
+		for _, key := range service.Keys {
+			fmt.Fprintf(w, "| `%s` | %s | %s | %s |\n", key.Key, key.Status, markdownCell(key.Target), markdownCell(key.Note))
+		}
+	}
+}
+
+// markdownCell escapes the pipes of a table cell.
+func markdownCell(text string) string {
+	return strings.Replace(text, "|", `\|`, -1)
+}